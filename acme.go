@@ -0,0 +1,465 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/libdns/libdns"
+	"github.com/pbergman/logger"
+	"golang.org/x/crypto/acme"
+)
+
+// ACMEConfig holds the settings for requesting the server certificate
+// with the ACME DNS-01 challenge, using the providers for the challenge
+// records. The certificate is written to the cert and key path of the
+// tls config and renewed before it expires.
+type ACMEConfig struct {
+	// Directory is the url of the ACME directory, defaults to Let's Encrypt
+	Directory string `json:"directory"`
+	// CA is an (optional) CA bundle to verify the directory with, which
+	// is useful when testing against a local Pebble instance.
+	CA    string `json:"ca"`
+	Email string `json:"email"`
+	// Domains to request the certificate for, which can contain wildcards
+	Domains []string `json:"domains"`
+	// Storage is the directory for the account key (and the
+	// certificate when no cert and key path are configured)
+	Storage string `json:"storage"`
+	// RenewBefore is the time before expiry the certificate is renewed
+	RenewBefore Duration `json:"renew_before"`
+	// PropagationDelay is the time to wait after creating the challenge
+	// records before the ACME server is asked to validate them.
+	PropagationDelay Duration `json:"propagation_delay"`
+}
+
+// StorageDir returns the storage directory, defaults to /var/lib/ddns-srv/acme
+func (c *ACMEConfig) StorageDir() string {
+
+	if c.Storage == "" {
+		return "/var/lib/ddns-srv/acme"
+	}
+
+	return c.Storage
+}
+
+func NewACMEManager(config *ACMEConfig, cert, key string, resolver *ZoneResolver, logger *logger.Logger) (*ACMEManager, error) {
+
+	if len(config.Domains) == 0 {
+		return nil, errors.New("acme requires at least one domain")
+	}
+
+	var manager = &ACMEManager{
+		config:   *config,
+		cert:     cert,
+		key:      key,
+		resolver: resolver,
+		logger:   logger.WithName("acme"),
+	}
+
+	if manager.config.Directory == "" {
+		manager.config.Directory = acme.LetsEncryptURL
+	}
+
+	if manager.config.RenewBefore <= 0 {
+		manager.config.RenewBefore = Duration(30 * 24 * time.Hour)
+	}
+
+	if manager.config.PropagationDelay <= 0 {
+		manager.config.PropagationDelay = Duration(30 * time.Second)
+	}
+
+	return manager, nil
+}
+
+// ACMEManager requests and renews the server certificate
+type ACMEManager struct {
+	config   ACMEConfig
+	cert     string
+	key      string
+	resolver *ZoneResolver
+	logger   *logger.Logger
+}
+
+// NeedsRenewal checks if the certificate is missing, expires within the
+// renew before duration (or a third of the lifetime for short-lived
+// certificates) or does not cover all configured domains.
+func (m *ACMEManager) NeedsRenewal() bool {
+
+	certificates, err := readCertificates(m.cert)
+
+	if err != nil {
+		return true
+	}
+
+	var lifetime = certificates[0].NotAfter.Sub(certificates[0].NotBefore)
+
+	if time.Until(certificates[0].NotAfter) < min(time.Duration(m.config.RenewBefore), lifetime/3) {
+		return true
+	}
+
+	for _, domain := range m.config.Domains {
+		if false == containsName(certificates[0].DNSNames, domain) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsName(names []string, name string) bool {
+
+	for _, x := range names {
+		if strings.EqualFold(x, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Run renews the certificate when needed, checking twice a day and
+// retrying every hour after a failure, until the context is done.
+func (m *ACMEManager) Run(ctx context.Context) {
+
+	for {
+		var wait = 12 * time.Hour
+
+		if m.NeedsRenewal() {
+			if err := m.Obtain(ctx); err != nil {
+				m.logger.Error(fmt.Sprintf("failed to renew certificate: %s", err.Error()))
+				wait = time.Hour
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (m *ACMEManager) client(ctx context.Context) (*acme.Client, error) {
+
+	key, err := m.accountKey()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var client = &acme.Client{
+		Key:          key,
+		DirectoryURL: m.config.Directory,
+		UserAgent:    "ddns-srv",
+	}
+
+	if m.config.CA != "" {
+
+		authorities, err := readCertificates(m.config.CA)
+
+		if err != nil {
+			return nil, err
+		}
+
+		var pool = x509.NewCertPool()
+
+		for _, authority := range authorities {
+			pool.AddCert(authority)
+		}
+
+		client.HTTPClient = &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		}
+	}
+
+	var account = new(acme.Account)
+
+	if m.config.Email != "" {
+		account.Contact = []string{"mailto:" + m.config.Email}
+	}
+
+	if _, err := client.Register(ctx, account, acme.AcceptTOS); err != nil && false == errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return nil, fmt.Errorf("failed to register account: %w", err)
+	}
+
+	return client, nil
+}
+
+// accountKey reads the account key from the storage, or creates one
+func (m *ACMEManager) accountKey() (*ecdsa.PrivateKey, error) {
+
+	var file = filepath.Join(m.config.StorageDir(), "account.key")
+
+	if data, err := os.ReadFile(file); err == nil {
+
+		block, _ := pem.Decode(data)
+
+		if block == nil {
+			return nil, fmt.Errorf("%s: invalid key", file)
+		}
+
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+
+		if x, ok := key.(*ecdsa.PrivateKey); ok {
+			return x, nil
+		}
+
+		return nil, fmt.Errorf("%s: unsupported key type", file)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := x509.MarshalPKCS8PrivateKey(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(m.config.StorageDir(), 0700); err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: data}), 0600); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// Obtain requests a new certificate and writes it (with a new key)
+// to the cert and key files of the tls config.
+func (m *ACMEManager) Obtain(ctx context.Context) error {
+
+	m.logger.Notice(fmt.Sprintf("requesting certificate for %s", strings.Join(m.config.Domains, ", ")))
+
+	client, err := m.client(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(m.config.Domains...))
+
+	if err != nil {
+		return err
+	}
+
+	for _, url := range order.AuthzURLs {
+		if err := m.authorize(ctx, client, url); err != nil {
+			return err
+		}
+	}
+
+	var uri = order.URI
+
+	if order, err = client.WaitOrder(ctx, uri); err != nil {
+		return err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		return err
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: m.config.Domains}, key)
+
+	if err != nil {
+		return err
+	}
+
+	chain, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+
+	if err != nil {
+		// servers that finalize asynchronously (like Pebble) may not return
+		// the order url on finalize, so wait for the order with the url we have
+		if order, _ = client.WaitOrder(ctx, uri); nil == order || order.Status != acme.StatusValid {
+			return err
+		}
+
+		if chain, err = client.FetchCert(ctx, order.CertURL, true); err != nil {
+			return err
+		}
+	}
+
+	data, err := x509.MarshalPKCS8PrivateKey(key)
+
+	if err != nil {
+		return err
+	}
+
+	var certificate []byte
+
+	for _, der := range chain {
+		certificate = append(certificate, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+
+	if err := writeFileAtomic(m.key, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: data}), 0600); err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(m.cert, certificate, 0644); err != nil {
+		return err
+	}
+
+	m.logger.Notice(fmt.Sprintf("certificate for %s written to '%s'", strings.Join(m.config.Domains, ", "), m.cert))
+
+	return nil
+}
+
+// authorize fulfills the dns-01 challenge of an authorization
+func (m *ACMEManager) authorize(ctx context.Context, client *acme.Client, url string) error {
+
+	authorization, err := client.GetAuthorization(ctx, url)
+
+	if err != nil {
+		return err
+	}
+
+	if authorization.Status == acme.StatusValid {
+		return nil
+	}
+
+	var challenge *acme.Challenge
+
+	for _, x := range authorization.Challenges {
+		if x.Type == "dns-01" {
+			challenge = x
+			break
+		}
+	}
+
+	if nil == challenge {
+		return fmt.Errorf("no dns-01 challenge for %s", authorization.Identifier.Value)
+	}
+
+	value, err := client.DNS01ChallengeRecord(challenge.Token)
+
+	if err != nil {
+		return err
+	}
+
+	var name = challengeName(authorization.Identifier.Value)
+
+	zone, err := resolveZone(ctx, m.resolver, name)
+
+	if err != nil {
+		return err
+	}
+
+	if err := appendChallengeRecord(ctx, zone, name, value); err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := deleteChallengeRecord(context.WithoutCancel(ctx), zone, name, value); err != nil {
+			m.logger.Error(fmt.Sprintf("failed to remove challenge record for %s: %s", authorization.Identifier.Value, err.Error()))
+		}
+	}()
+
+	m.logger.Debug(fmt.Sprintf("waiting %s for the challenge record of %s", time.Duration(m.config.PropagationDelay), authorization.Identifier.Value))
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Duration(m.config.PropagationDelay)):
+	}
+
+	if _, err := client.Accept(ctx, challenge); err != nil {
+		return err
+	}
+
+	_, err = client.WaitAuthorization(ctx, authorization.URI)
+
+	return err
+}
+
+// challengeName returns the name of the dns-01 challenge record for
+// the domain, which is the same for a domain and its wildcard.
+func challengeName(domain string) string {
+	return "_acme-challenge." + strings.ToLower(strings.TrimPrefix(strings.TrimSuffix(domain, "."), "*."))
+}
+
+// resolveZone returns the (preferred) zone that serves the name
+func resolveZone(ctx context.Context, resolver *ZoneResolver, name string) (*ZoneEntry, error) {
+
+	table, err := resolver.Fetch(ctx, NewSemaphore(5))
+
+	if nil == table {
+		return nil, err
+	}
+
+	var matches = table.Resolve(name)
+
+	if len(matches) == 0 {
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("no zone found for '%s'", name)
+	}
+
+	return matches[0], nil
+}
+
+// appendChallengeRecord adds a TXT record for a dns-01 challenge with
+// a short TTL, clamped to the range supported by the provider.
+func appendChallengeRecord(ctx context.Context, zone *ZoneEntry, name, value string) error {
+
+	var record = libdns.TXT{
+		Name: zone.RelativeName(name),
+		TTL:  zone.Plugin.TTLPolicy().Clamp(time.Minute),
+		Text: value,
+	}
+
+	if _, err := zone.Plugin.AppendRecords(ctx, zone.Name, []libdns.Record{record}); err != nil {
+		return fmt.Errorf("failed to create challenge record '%s': %w", name, err)
+	}
+
+	return nil
+}
+
+// deleteChallengeRecord removes the TXT record of a dns-01 challenge, which
+// is matched by name and value only (so a TTL changed by the provider does
+// not prevent the removal).
+func deleteChallengeRecord(ctx context.Context, zone *ZoneEntry, name, value string) error {
+	_, err := zone.Plugin.DeleteRecords(ctx, zone.Name, []libdns.Record{libdns.TXT{Name: zone.RelativeName(name), Text: value}})
+	return err
+}
+
+// writeFileAtomic writes the file by writing a temporary file first
+// and renaming it, so readers never see a partially written file.
+func writeFileAtomic(file string, data []byte, perm os.FileMode) error {
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+
+	var tmp = file + ".tmp"
+
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, file)
+}
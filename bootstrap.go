@@ -26,7 +26,9 @@ func bootstrap(log *logger.Logger, file string, level provider.OutputLevel) (*Co
 		return nil, nil, err
 	}
 
-	var providers = make([]PluginProvider, len(plugins))
+	// a provider for every configured plugin, as a loaded plugin
+	// can be configured multiple times (or not at all)
+	var providers = make([]PluginProvider, len(config.Plugins))
 
 	for i, c := 0, len(config.Plugins); i < c; i++ {
 		var base PluginConfig
@@ -59,7 +61,7 @@ func bootstrap(log *logger.Logger, file string, level provider.OutputLevel) (*Co
 			v.SetDebug(level, log.WithName(base.Plugin).NewWriter(logger.Debug))
 		}
 
-		providers[i] = &Provider{ZoneAwareProvider: object.(ZoneAwareProvider), module: ref.build}
+		providers[i] = &Provider{ZoneAwareProvider: object.(ZoneAwareProvider), module: ref.build, priority: base.Priority, ttl: base.TTL}
 	}
 
 	return config, providers, nil
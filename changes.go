@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+type ChangeKind string
+
+const (
+	ChangeCreate ChangeKind = "create"
+	ChangeUpdate ChangeKind = "update"
+	ChangeDelete ChangeKind = "delete"
+)
+
+// RecordChange is a change of a single RRset (records with the
+// same name and type) from the current to the desired records.
+type RecordChange struct {
+	Kind    ChangeKind
+	Key     string
+	Current []libdns.RR
+	Desired []libdns.RR
+}
+
+// DiffRecords returns the changes needed to get from the current to the
+// desired records of a zone, ordered by name and type. RRsets that are not
+// in the desired records are only deleted when deletes is true.
+func DiffRecords(zone string, current, desired []libdns.Record, deletes bool) []*RecordChange {
+
+	var have = NewRecordSet(zone, current...)
+	var want = NewRecordSet(zone, desired...)
+	var changes = make([]*RecordChange, 0)
+
+	for key, records := range want {
+
+		if _, ok := have[key]; false == ok {
+			changes = append(changes, &RecordChange{Kind: ChangeCreate, Key: key, Desired: records})
+			continue
+		}
+
+		if false == have.Equal(key, records) {
+			changes = append(changes, &RecordChange{Kind: ChangeUpdate, Key: key, Current: have[key], Desired: records})
+		}
+	}
+
+	if deletes {
+		for key, records := range have {
+			if _, ok := want[key]; false == ok {
+				changes = append(changes, &RecordChange{Kind: ChangeDelete, Key: key, Current: records})
+			}
+		}
+	}
+
+	slices.SortFunc(changes, func(a, b *RecordChange) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	return changes
+}
+
+// WriteChanges writes the changes as a diff where removed records are
+// prefixed with a minus and added records with a plus, followed by a
+// summary of the number of changes.
+func WriteChanges(writer io.Writer, zone string, changes []*RecordChange) error {
+
+	var tab = tabwriter.NewWriter(writer, 0, 8, 1, ' ', 0)
+	var counts = make(map[ChangeKind]int)
+
+	for _, change := range changes {
+
+		counts[change.Kind]++
+
+		for _, rr := range change.Current {
+			_, _ = fmt.Fprintf(tab, "-\t%s\n", formatChange(rr, zone))
+		}
+
+		for _, rr := range change.Desired {
+			_, _ = fmt.Fprintf(tab, "+\t%s\n", formatChange(rr, zone))
+		}
+	}
+
+	if err := tab.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(writer, "%s: %d to create, %d to update, %d to delete\n", zone, counts[ChangeCreate], counts[ChangeUpdate], counts[ChangeDelete])
+
+	return err
+}
+
+func formatChange(rr libdns.RR, zone string) string {
+	return fmt.Sprintf("%s\t%d\tIN\t%s\t%s", libdns.AbsoluteName(rr.Name, zone), int64(rr.TTL/time.Second), rr.Type, zoneData(rr))
+}
+
+// ApplyChanges applies the changes to the zone, where created RRsets are
+// appended, updated RRsets are set (which replaces the existing records)
+// and the records of deleted RRsets are deleted.
+func ApplyChanges(ctx context.Context, provider PluginProvider, zone string, changes []*RecordChange) error {
+
+	var records = make(map[ChangeKind][]libdns.Record)
+
+	for _, change := range changes {
+
+		var items = change.Desired
+
+		if change.Kind == ChangeDelete {
+			items = change.Current
+		}
+
+		for _, rr := range items {
+			records[change.Kind] = append(records[change.Kind], parseRR(rr))
+		}
+	}
+
+	var calls = []struct {
+		kind ChangeKind
+		call func(context.Context, string, []libdns.Record) ([]libdns.Record, error)
+	}{
+		{ChangeDelete, provider.DeleteRecords},
+		{ChangeUpdate, provider.SetRecords},
+		{ChangeCreate, provider.AppendRecords},
+	}
+
+	for _, x := range calls {
+
+		if len(records[x.kind]) == 0 {
+			continue
+		}
+
+		if _, err := x.call(ctx, zone, records[x.kind]); err != nil {
+			return fmt.Errorf("%s: failed to %s records in zone %s: %w", provider.Module().Path, x.kind, zone, err)
+		}
+	}
+
+	return nil
+}
+
+// parseRR returns the record as libdns type or as RR when it could not be parsed
+func parseRR(rr libdns.RR) libdns.Record {
+
+	if record, err := rr.Parse(); err == nil {
+		return record
+	}
+
+	return rr
+}
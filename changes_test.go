@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+func TestDiffRecordsTargets(t *testing.T) {
+
+	// the records from a zone file have absolute targets, where
+	// providers can return the same targets without trailing dot
+	var desired = []libdns.Record{
+		libdns.RR{Name: "@", TTL: time.Hour, Type: "MX", Data: "10 mail.example.com."},
+		libdns.RR{Name: "_sip._tcp", TTL: time.Hour, Type: "SRV", Data: "10 5 5060 sip.example.com."},
+		libdns.RR{Name: "1", TTL: time.Hour, Type: "PTR", Data: "host.example.com."},
+		libdns.RR{Name: "old", TTL: time.Hour, Type: "DNAME", Data: "new.example.com."},
+		libdns.RR{Name: "www", TTL: time.Hour, Type: "CNAME", Data: "Example.com."},
+		libdns.RR{Name: "sub", TTL: time.Hour, Type: "NS", Data: "ns1.example.com."},
+	}
+
+	var current = []libdns.Record{
+		libdns.RR{Name: "@", TTL: time.Hour, Type: "MX", Data: "10  mail.example.com"},
+		libdns.RR{Name: "_sip._tcp", TTL: time.Hour, Type: "SRV", Data: "10 5 5060 sip.example.com"},
+		libdns.RR{Name: "1", TTL: time.Hour, Type: "PTR", Data: "host.example.com"},
+		libdns.RR{Name: "old", TTL: time.Hour, Type: "DNAME", Data: "new.example.com"},
+		libdns.RR{Name: "www", TTL: time.Hour, Type: "CNAME", Data: "example.com"},
+		libdns.RR{Name: "sub", TTL: time.Hour, Type: "NS", Data: "ns1.example.com"},
+	}
+
+	if changes := DiffRecords("example.com.", current, desired, true); len(changes) != 0 {
+		t.Errorf("expected no changes, got %d (%s)", len(changes), changes[0].Key)
+	}
+}
+
+func TestDiffRecords(t *testing.T) {
+
+	var current = []libdns.Record{
+		libdns.RR{Name: "www", TTL: time.Hour, Type: "A", Data: "192.0.2.1"},
+		libdns.RR{Name: "www", TTL: time.Hour, Type: "A", Data: "192.0.2.2"},
+		libdns.RR{Name: "_sip._tcp", TTL: time.Hour, Type: "SRV", Data: "10 5 5060 sip.example.com"},
+		libdns.RR{Name: "old", TTL: time.Hour, Type: "TXT", Data: "old"},
+	}
+
+	var desired = []libdns.Record{
+		libdns.RR{Name: "www", TTL: time.Hour, Type: "A", Data: "192.0.2.2"},
+		libdns.RR{Name: "www", TTL: time.Hour, Type: "A", Data: "::ffff:192.0.2.1"},
+		libdns.RR{Name: "_sip._tcp", TTL: time.Hour, Type: "SRV", Data: "10 5 5061 sip.example.com."},
+		libdns.RR{Name: "new", TTL: time.Hour, Type: "TXT", Data: "new"},
+	}
+
+	var expected = []struct {
+		kind ChangeKind
+		key  string
+	}{
+		{ChangeUpdate, "_sip._tcp.example.com SRV"},
+		{ChangeCreate, "new.example.com TXT"},
+		{ChangeDelete, "old.example.com TXT"},
+	}
+
+	var changes = DiffRecords("example.com.", current, desired, true)
+
+	if len(changes) != len(expected) {
+		t.Fatalf("expected %d changes got %d", len(expected), len(changes))
+	}
+
+	for idx, change := range changes {
+		if change.Kind != expected[idx].kind || change.Key != expected[idx].key {
+			t.Errorf("change %d: expected %s %s got %s %s", idx, expected[idx].kind, expected[idx].key, change.Kind, change.Key)
+		}
+	}
+
+	if changes = DiffRecords("example.com.", current, desired, false); len(changes) != 2 {
+		t.Errorf("expected 2 changes without deletes, got %d", len(changes))
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// NewCertificateAuthenticator creates an authenticator that maps the
+// identities of verified client certificates to the configured users.
+func NewCertificateAuthenticator(users *UserList) *CertificateAuthenticator {
+
+	var identities = make(map[string]*User)
+
+	if nil != users {
+		for _, user := range *users {
+			if nil != user {
+				for _, identity := range user.Certificates {
+					identities[strings.ToLower(identity)] = user
+				}
+			}
+		}
+	}
+
+	return &CertificateAuthenticator{identities: identities}
+}
+
+type CertificateAuthenticator struct {
+	identities map[string]*User
+}
+
+func (c *CertificateAuthenticator) Authenticate(request *http.Request) (*User, error) {
+
+	if nil == request.TLS || len(request.TLS.VerifiedChains) == 0 || len(request.TLS.VerifiedChains[0]) == 0 {
+		return nil, nil
+	}
+
+	var certificate = request.TLS.VerifiedChains[0][0]
+
+	for _, identity := range certificateIdentities(certificate) {
+		if user, ok := c.identities[strings.ToLower(identity)]; ok {
+			return user, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no user for certificate %s", certificate.Subject)
+}
+
+func (c *CertificateAuthenticator) Challenge() string {
+	return ""
+}
+
+// certificateIdentities returns the identities of the certificate, the
+// full subject (like CN=router,O=Home), the common name and the dns,
+// email and uri subject alternative names.
+func certificateIdentities(certificate *x509.Certificate) []string {
+
+	var identities = []string{certificate.Subject.String()}
+
+	if certificate.Subject.CommonName != "" {
+		identities = append(identities, certificate.Subject.CommonName)
+	}
+
+	identities = append(identities, certificate.DNSNames...)
+	identities = append(identities, certificate.EmailAddresses...)
+
+	for _, uri := range certificate.URIs {
+		identities = append(identities, uri.String())
+	}
+
+	return identities
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os/signal"
+	"syscall"
+
+	"github.com/pbergman/logger"
+)
+
+// mirror runs the mirrors from the config until interrupted
+func mirror(config *Config, resolver *ZoneResolver, logger *logger.Logger) error {
+
+	if len(config.Mirrors) == 0 {
+		return errors.New("no mirrors defined in config")
+	}
+
+	var ctx, stop = signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+
+	defer stop()
+
+	RunMirrors(ctx, config.Mirrors, resolver, logger)
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// passwd reads a password from stdin and prints a users file
+// line (user:hash) hashed with the given algorithm.
+func passwd(stdin *os.File, stdout, stderr io.Writer, args ...string) error {
+
+	var algorithm, user string
+
+	switch len(args) {
+	case 1:
+		algorithm, user = "pbkdf2", args[0]
+	case 2:
+		algorithm, user = args[0], args[1]
+	default:
+		return fmt.Errorf("Usage: %s passwd [%s] <user>", os.Args[0], strings.Join(PasswordAlgorithms, "|"))
+	}
+
+	if strings.Contains(user, ":") {
+		return errors.New("user can not contain a colon")
+	}
+
+	if stat, err := stdin.Stat(); err == nil && 0 != (stat.Mode()&os.ModeCharDevice) {
+		_, _ = fmt.Fprint(stderr, "Password: ")
+	}
+
+	line, err := bufio.NewReader(stdin).ReadString('\n')
+
+	if err != nil && false == errors.Is(err, io.EOF) {
+		return err
+	}
+
+	if line = strings.TrimRight(line, "\r\n"); line == "" {
+		return errors.New("empty password")
+	}
+
+	hash, err := HashPassword(algorithm, line)
+
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(stdout, "%s:%s\n", user, hash)
+
+	return nil
+}
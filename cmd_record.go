@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/libdns/libdns"
+)
+
+// record gets, sets, appends or deletes records of a zone with the
+// (preferred) provider of that zone, or the provider of given module
+// when multiple providers serve the zone, and prints the records returned
+// by the provider. The data of a record is in zone file format so TXT
+// records can be given as (multiple) quoted strings and names in the
+// data (like the target of a CNAME) are relative to the zone.
+func record(ctx context.Context, resolver *ZoneResolver, command, module string, format Format, stdout io.Writer, args ...string) error {
+
+	switch {
+	case command == "get" && (len(args) < 1 || len(args) > 3):
+		return fmt.Errorf("Usage: %s get <zone> [name] [type]", os.Args[0])
+	case command == "delete" && len(args) < 2:
+		return fmt.Errorf("Usage: %s delete <zone> <name> [type] [ttl] [data]", os.Args[0])
+	case (command == "set" || command == "append") && len(args) < 5:
+		return fmt.Errorf("Usage: %s %s <zone> <name> <type> <ttl> <data>", os.Args[0], command)
+	}
+
+	zone, err := findZone(ctx, resolver, args[0], module)
+
+	if err != nil {
+		return err
+	}
+
+	var result []libdns.Record
+
+	if command == "get" {
+
+		records, err := zone.Plugin.GetRecords(ctx, zone.Name)
+
+		if err != nil {
+			return err
+		}
+
+		result = filterRecords(records, zone.Name, argument(args, 1), argument(args, 2))
+
+	} else {
+
+		record, err := parseRecordArgs(zone.Name, command != "delete", args[1:]...)
+
+		if err != nil {
+			return err
+		}
+
+		var call func(context.Context, string, []libdns.Record) ([]libdns.Record, error)
+
+		switch command {
+		case "set":
+			call = zone.Plugin.SetRecords
+		case "append":
+			call = zone.Plugin.AppendRecords
+		default:
+			call = zone.Plugin.DeleteRecords
+		}
+
+		if result, err = call(ctx, zone.Name, []libdns.Record{record}); err != nil {
+			return fmt.Errorf("%s: failed to %s records in zone %s: %w", zone.Plugin.Module().Path, command, zone.Name, err)
+		}
+	}
+
+	return printRecords(zone, result, format, stdout)
+}
+
+// findZone returns the (preferred) provider of the zone, or the provider
+// of given module when multiple providers serve the zone. Plugins that
+// failed listing their zones are only reported when the zone is not found.
+func findZone(ctx context.Context, resolver *ZoneResolver, name, module string) (*ZoneEntry, error) {
+
+	table, err := resolver.Fetch(ctx, NewSemaphore(5))
+
+	for _, entry := range table.Lookup(name) {
+		if module == "" || entry.Plugin.Module().Path == module {
+			return entry, nil
+		}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("zone '%s' not found: %w", name, err)
+	}
+
+	return nil, fmt.Errorf("zone '%s' not found", name)
+}
+
+// parseRecordArgs parses the name, type, ttl and data arguments to a record
+// where for a delete (so when parse is false) all but the name are optional.
+func parseRecordArgs(zone string, parse bool, args ...string) (libdns.Record, error) {
+
+	var item = &ApiRecord{
+		Name: args[0],
+		Type: argument(args, 1),
+		Data: strings.Join(args[min(len(args), 3):], " "),
+	}
+
+	if ttl := argument(args, 2); ttl != "" {
+
+		value, err := parseTTL(ttl)
+
+		if err != nil {
+			return nil, err
+		}
+
+		item.TTL = int64(value.Seconds())
+	}
+
+	if item.Data != "" {
+
+		data, err := newZoneParser(zone).data(strings.ToUpper(item.Type), zoneFields(item.Data))
+
+		if err != nil {
+			return nil, errors.New("invalid data: " + err.Error())
+		}
+
+		item.Data = data
+	}
+
+	return item.Record(zone, parse)
+}
+
+func printRecords(zone *ZoneEntry, records []libdns.Record, format Format, stdout io.Writer) error {
+
+	var rows = newRecordRows(zone.Plugin, zone.Name, records)
+
+	if format != FormatTable {
+
+		var encoder = NewRowEncoder(format, stdout, recordColumns)
+
+		for _, row := range rows {
+			_ = encoder.Encode(row)
+		}
+
+		return encoder.Close()
+	}
+
+	var tab = tabwriter.NewWriter(stdout, 0, 2, 2, ' ', 0)
+
+	for _, row := range rows {
+
+		var data = row.Data
+
+		if row.Type == "TXT" {
+			data = quoteText(data)
+		}
+
+		_, _ = fmt.Fprintf(tab, "%s\t%d\t%s\t%s\n", row.Name, row.TTL, row.Type, data)
+	}
+
+	return tab.Flush()
+}
+
+func argument(args []string, idx int) string {
+
+	if idx < len(args) {
+		return args[idx]
+	}
+
+	return ""
+}
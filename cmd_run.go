@@ -37,11 +37,28 @@ func run(logger *logger.Logger, level provider.OutputLevel) {
 		panic(err)
 	}
 
-	var srv = NewServer(ctx, config, logger, providers)
+	srv, err := NewServer(ctx, config, logger, providers)
+
+	if err != nil {
+		panic(err)
+	}
+
+	if len(config.Mirrors) > 0 {
+		go RunMirrors(ctx, config.Mirrors, NewZoneResolver(providers), logger)
+	}
 
 	go func() {
 		logger.Debug(fmt.Sprintf("listening on %s", srv.Addr))
-		if err := srv.ListenAndServe(); err != nil && false == errors.Is(err, http.ErrServerClosed) {
+
+		var err error
+
+		if nil != srv.TLSConfig {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+
+		if err != nil && false == errors.Is(err, http.ErrServerClosed) {
 			logger.Error(err.Error())
 		}
 	}()
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// sign prints the query of a signed update request for given
+// host, which is signed with the secret from the config.
+func sign(file string, stdout io.Writer, args ...string) error {
+
+	if len(args) == 0 || len(args) > 2 {
+		return fmt.Errorf("Usage: %s sign <hostname> [myip]", os.Args[0])
+	}
+
+	config, err := ReadConfig(file)
+
+	if err != nil {
+		return err
+	}
+
+	var hostname = strings.ToLower(strings.TrimSuffix(args[0], "."))
+	var policy = config.Server.Hosts.Get(hostname)
+
+	if policy.Secret == "" {
+		return fmt.Errorf("no secret configured for '%s'", hostname)
+	}
+
+	var query = url.Values{"hostname": {hostname}}
+
+	if len(args) == 2 {
+		query.Set("myip", args[1])
+	}
+
+	if err := SignUpdate(query, policy.Secret, time.Now()); err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(stdout, "/nic/update?%s\n", query.Encode())
+
+	return nil
+}
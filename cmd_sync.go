@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// syncZones prints the changes needed to get the zones in sync with the
+// records of the sync file and, when apply is true, applies those changes.
+// When zones are given, only those zones of the sync file are synced.
+func syncZones(ctx context.Context, config *Config, resolver *ZoneResolver, apply bool, stdout io.Writer, args ...string) error {
+
+	if len(args) < 1 {
+		return fmt.Errorf("Usage: %s plan|apply <file> [zone...]", os.Args[0])
+	}
+
+	syncConfig, err := ReadSyncConfig(args[0])
+
+	if err != nil {
+		return err
+	}
+
+	var names = make([]string, 0, len(syncConfig.Zones))
+
+	for name := range syncConfig.Zones {
+		if len(args) == 1 || inSlice(args[1:], name) {
+			names = append(names, name)
+		}
+	}
+
+	for _, name := range args[1:] {
+		if _, ok := syncConfig.Zones[name]; false == ok {
+			return fmt.Errorf("zone '%s' not defined in %s", name, args[0])
+		}
+	}
+
+	sort.Strings(names)
+
+	var errs = make([]error, 0)
+
+	for _, name := range names {
+
+		if err := syncZone(ctx, config, resolver, syncConfig, name, apply, stdout); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func syncZone(ctx context.Context, config *Config, resolver *ZoneResolver, syncConfig *SyncConfig, name string, apply bool, stdout io.Writer) error {
+
+	var zone = syncConfig.Zones[name]
+
+	entry, err := findZone(ctx, resolver, name, zone.Module)
+
+	if err != nil {
+		return err
+	}
+
+	changes, err := PlanZone(ctx, entry, zone, NewSyncExcludes(syncConfig, config.Server, zone))
+
+	if err != nil {
+		return err
+	}
+
+	if err := WriteChanges(stdout, fqdn(entry.Name), changes); err != nil {
+		return err
+	}
+
+	if false == apply || len(changes) == 0 {
+		return nil
+	}
+
+	if err := ApplyChanges(ctx, entry.Plugin, entry.Name, changes); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(stdout, "applied %d change(s) to %s\n", len(changes), fqdn(entry.Name))
+
+	return err
+}
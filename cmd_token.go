@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// token creates a new api token and prints the token together with
+// the config entry (holding only the hash) for the tokens config.
+func token(stdout io.Writer, expires time.Duration, args ...string) error {
+
+	if len(args) == 0 {
+		return fmt.Errorf("Usage: %s [-expires <duration>] token <id> [update|read|acme|admin...] [host=<glob>...] [zone=<zone>...]", os.Args[0])
+	}
+
+	var entry = new(Token)
+
+	for _, arg := range args[1:] {
+		switch {
+		case strings.HasPrefix(arg, "host="):
+			entry.Hosts = append(entry.Hosts, arg[5:])
+		case strings.HasPrefix(arg, "zone="):
+			entry.Zones = append(entry.Zones, arg[5:])
+		case Role(arg).IsValid():
+			entry.Scopes = append(entry.Scopes, Role(arg))
+		default:
+			return fmt.Errorf("invalid argument '%s', expected a scope (update, read, acme or admin), host=<glob> or zone=<zone>", arg)
+		}
+	}
+
+	if expires > 0 {
+		var at = time.Now().Add(expires).UTC().Truncate(time.Second)
+		entry.Expires = &at
+	}
+
+	value, hash, err := NewToken()
+
+	if err != nil {
+		return err
+	}
+
+	entry.Hash = hash
+
+	config, err := json.MarshalIndent(TokenList{args[0]: entry}, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(stdout, "token: %s\n\n%s\n", value, config)
+
+	return nil
+}
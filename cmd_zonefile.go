@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// exportZone writes the records of the zone as zone file to given
+// file or, when no file is given, to stdout.
+func exportZone(ctx context.Context, resolver *ZoneResolver, module string, stdout io.Writer, args ...string) error {
+
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("Usage: %s export <zone> [file]", os.Args[0])
+	}
+
+	zone, err := findZone(ctx, resolver, args[0], module)
+
+	if err != nil {
+		return err
+	}
+
+	records, err := zone.Plugin.GetRecords(ctx, zone.Name)
+
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+
+	_, _ = fmt.Fprintf(&buf, "; %s exported from %s at %s\n", fqdn(zone.Name), zone.Plugin.Module().Path, time.Now().UTC().Format(time.RFC3339))
+
+	if err := WriteZoneFile(&buf, zone.Name, records); err != nil {
+		return err
+	}
+
+	if len(args) == 2 && args[1] != "-" {
+		return writeFileAtomic(args[1], buf.Bytes(), 0644)
+	}
+
+	_, err = stdout.Write(buf.Bytes())
+
+	return err
+}
+
+// importZone reads the records from the zone file and prints the changes
+// needed to get to those records. When not a dry run, the RRsets that
+// not yet exist are appended and the RRsets that differ are set. RRsets
+// of the zone that are not in the file are left untouched and SOA and
+// apex NS records are skipped as those are managed by the provider.
+func importZone(ctx context.Context, resolver *ZoneResolver, module string, dryRun bool, stdin io.Reader, stdout io.Writer, args ...string) error {
+
+	if len(args) != 2 {
+		return fmt.Errorf("Usage: %s import <zone> <file>", os.Args[0])
+	}
+
+	zone, err := findZone(ctx, resolver, args[0], module)
+
+	if err != nil {
+		return err
+	}
+
+	var reader = stdin
+
+	if args[1] != "-" {
+
+		file, err := os.Open(args[1])
+
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+
+		reader = file
+	}
+
+	records, err := ParseZoneFile(reader, zone.Name)
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", args[1], err)
+	}
+
+	var desired = make([]libdns.Record, 0, len(records))
+
+	for _, record := range records {
+		if rr := record.RR(); isProviderManaged(rr, zone.Name) {
+			_, _ = fmt.Fprintf(stdout, "skipping %s record for %s\n", rr.Type, libdns.AbsoluteName(rr.Name, zone.Name))
+		} else {
+			desired = append(desired, record)
+		}
+	}
+
+	current, err := zone.Plugin.GetRecords(ctx, zone.Name)
+
+	if err != nil {
+		return err
+	}
+
+	var changes = DiffRecords(zone.Name, current, desired, false)
+
+	if err := WriteChanges(stdout, fqdn(zone.Name), changes); err != nil {
+		return err
+	}
+
+	if dryRun || len(changes) == 0 {
+		return nil
+	}
+
+	if err := ApplyChanges(ctx, zone.Plugin, zone.Name, changes); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(stdout, "applied %d change(s) to %s\n", len(changes), fqdn(zone.Name))
+
+	return err
+}
+
+// isProviderManaged returns true for the SOA and NS records of the zone
+// apex, which are managed by the provider and can not be changed.
+func isProviderManaged(rr libdns.RR, zone string) bool {
+	return (strings.EqualFold(rr.Type, "SOA") || strings.EqualFold(rr.Type, "NS")) && libdns.RelativeName(libdns.AbsoluteName(rr.Name, zone), zone) == "@"
+}
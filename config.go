@@ -2,18 +2,110 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"strings"
+	"time"
 )
 
 type PluginConfig struct {
-	Plugin string   `json:"plugin"`
-	Zones  []string `json:"zones,omitempty"`
+	Plugin   string     `json:"plugin"`
+	Zones    []string   `json:"zones,omitempty"`
+	Priority int        `json:"priority,omitempty"`
+	TTL      *TTLPolicy `json:"ttl,omitempty"`
+}
+
+// Duration can be unmarshalled from a duration string (like "5m")
+// or from a number that represents the duration in seconds.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+
+	var value any
+
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	switch x := value.(type) {
+	case float64:
+		*d = Duration(time.Duration(x) * time.Second)
+	case string:
+		v, err := time.ParseDuration(x)
+
+		if err != nil {
+			return err
+		}
+
+		*d = Duration(v)
+	default:
+		return fmt.Errorf("invalid duration %s", string(data))
+	}
+
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// TTLPolicy defines the TTL of the records written to a provider
+type TTLPolicy struct {
+	// Default is the TTL used for all zones of the provider
+	Default Duration `json:"default"`
+	// Min and Max will clamp the TTL to the range supported by the provider
+	Min Duration `json:"min"`
+	Max Duration `json:"max"`
+	// Keep will use the TTL of existing records instead of the configured TTL
+	Keep bool `json:"keep"`
+	// Zones defines the TTL per zone
+	Zones map[string]Duration `json:"zones"`
+}
+
+// Get returns the TTL for given zone, falling back to the provider
+// default and when that is not defined to given fallback.
+func (t *TTLPolicy) Get(zone string, fallback time.Duration) time.Duration {
+
+	if nil == t {
+		return fallback
+	}
+
+	for name, ttl := range t.Zones {
+		if strings.EqualFold(strings.TrimSuffix(name, "."), strings.TrimSuffix(zone, ".")) && ttl > 0 {
+			return time.Duration(ttl)
+		}
+	}
+
+	if t.Default > 0 {
+		return time.Duration(t.Default)
+	}
+
+	return fallback
+}
+
+// Clamp makes sure the TTL is within the range supported by the provider
+func (t *TTLPolicy) Clamp(ttl time.Duration) time.Duration {
+
+	if nil == t {
+		return ttl
+	}
+
+	if t.Min > 0 && ttl < time.Duration(t.Min) {
+		ttl = time.Duration(t.Min)
+	}
+
+	if t.Max > 0 && ttl > time.Duration(t.Max) {
+		ttl = time.Duration(t.Max)
+	}
+
+	return ttl
 }
 
 type Config struct {
 	PluginDir string            `json:"plugin_dir"`
 	Server    *ServerConfig     `json:"server"`
 	Plugins   []json.RawMessage `json:"plugins"`
+	Mirrors   []*MirrorConfig   `json:"mirrors"`
 }
 
 func ReadConfig(file string) (*Config, error) {
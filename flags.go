@@ -5,12 +5,17 @@ import (
 	"fmt"
 	"os"
 	"text/tabwriter"
+	"time"
 )
 
 func init() {
 	flag.Bool("debug", false, "debug mode")
 	flag.Int("provider-debug-level", 2, "when in debug mode and prover supports debug interface, this wil set the level (1, 2 or 3)")
 	flag.String("config", "/etc/ddns-srv.conf", "config file")
+	flag.String("format", "table", "output format of the records, zones, lookup, inspect and record commands (table, json, ndjson, csv or yaml)")
+	flag.String("module", "", "module of the provider used by the get, set, append, delete, export and import commands when a zone is served by multiple providers")
+	flag.Bool("dry-run", false, "only print the changes of the import command")
+	flag.Duration("expires", 0, "expiry of tokens created with the token command (0 is no expiry)")
 
 	flag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s <package...>\n", os.Args[0])
@@ -25,6 +30,18 @@ func init() {
 		fmt.Fprintln(tab, "  records\t[module...]\tprint record")
 		fmt.Fprintln(tab, "  zones\t[module...]\tprint zones")
 		fmt.Fprintln(tab, "  inspect\t[module...]\tprint plugin information")
+		fmt.Fprintln(tab, "  get\t<zone> [name] [type]\tprint the records of a zone")
+		fmt.Fprintln(tab, "  set\t<zone> <name> <type> <ttl> <data>\treplace the records with the same name and type")
+		fmt.Fprintln(tab, "  append\t<zone> <name> <type> <ttl> <data>\tadd a record")
+		fmt.Fprintln(tab, "  delete\t<zone> <name> [type] [ttl] [data]\tdelete the matching records")
+		fmt.Fprintln(tab, "  export\t<zone> [file]\twrite the records of a zone as zone file")
+		fmt.Fprintln(tab, "  import\t<zone> <file>\tcreate or update the records of a zone from a zone file")
+		fmt.Fprintln(tab, "  plan\t<file> [zone...]\tprint the changes needed to sync the zones with the records of the sync file")
+		fmt.Fprintln(tab, "  apply\t<file> [zone...]\tsync the zones with the records of the sync file")
+		fmt.Fprintln(tab, "  mirror\t\tmirror the zones as defined in the config (also done by run)")
+		fmt.Fprintln(tab, "  passwd\t[algorithm] <user>\tgenerate a password hash (read from stdin) for the users config or file")
+		fmt.Fprintln(tab, "  sign\t<hostname> [myip]\tprint a signed update query for a host with a secret")
+		fmt.Fprintln(tab, "  token\t<id> [scope...] [host=<glob>...] [zone=<zone>...]\tcreate an api token and print the config entry")
 		fmt.Fprintln(tab, "  version\t\tprint version of application")
 
 		tab.Flush()
@@ -32,7 +49,7 @@ func init() {
 
 }
 
-func inputOption[T bool | string | int](name string, empty T) T {
+func inputOption[T bool | string | int | time.Duration](name string, empty T) T {
 
 	var input = flag.Lookup(name)
 
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type Format string
+
+const (
+	FormatTable  Format = "table"
+	FormatJSON   Format = "json"
+	FormatNDJSON Format = "ndjson"
+	FormatCSV    Format = "csv"
+	FormatYAML   Format = "yaml"
+)
+
+func ParseFormat(value string) (Format, error) {
+
+	switch format := Format(strings.ToLower(strings.TrimSpace(value))); format {
+	case "":
+		return FormatTable, nil
+	case FormatTable, FormatJSON, FormatNDJSON, FormatCSV, FormatYAML:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unsupported format %q (table, json, ndjson, csv or yaml)", value)
+	}
+}
+
+// NegotiateFormat returns the format requested with the format query
+// parameter or else the first supported media type from the accept header.
+func NegotiateFormat(request *http.Request) (Format, error) {
+
+	if request.URL.Query().Has("format") {
+		return ParseFormat(request.URL.Query().Get("format"))
+	}
+
+	for _, item := range strings.Split(strings.Join(request.Header.Values("Accept"), ","), ",") {
+
+		media, _, err := mime.ParseMediaType(strings.TrimSpace(item))
+
+		if err != nil {
+			continue
+		}
+
+		switch media {
+		case "application/json":
+			return FormatJSON, nil
+		case "application/x-ndjson", "application/ndjson", "application/jsonl":
+			return FormatNDJSON, nil
+		case "text/csv":
+			return FormatCSV, nil
+		case "application/yaml", "application/x-yaml", "text/yaml":
+			return FormatYAML, nil
+		case "text/plain":
+			return FormatTable, nil
+		}
+	}
+
+	return FormatTable, nil
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case FormatJSON:
+		return "application/json"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatYAML:
+		return "application/yaml"
+	default:
+		return "text/plain; charset=utf-8"
+	}
+}
+
+// Row is a single entry of the machine-readable output where the
+// values are in the same order as the columns given to the encoder.
+type Row interface {
+	Values() []any
+}
+
+var recordColumns = []string{"module", "zone", "name", "type", "ttl", "data"}
+
+type RecordRow struct {
+	Module string `json:"module"`
+	Zone   string `json:"zone"`
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	TTL    int64  `json:"ttl"`
+	Data   string `json:"data"`
+}
+
+func (r *RecordRow) Values() []any {
+	return []any{r.Module, r.Zone, r.Name, r.Type, r.TTL, r.Data}
+}
+
+var zoneColumns = []string{"module", "zone"}
+
+type ZoneRow struct {
+	Module string `json:"module"`
+	Zone   string `json:"zone"`
+}
+
+func (z *ZoneRow) Values() []any {
+	return []any{z.Module, z.Zone}
+}
+
+var pluginColumns = []string{"module", "version", "sum", "replace"}
+
+type PluginRow struct {
+	Module  string `json:"module"`
+	Version string `json:"version"`
+	Sum     string `json:"sum"`
+	Replace string `json:"replace"`
+}
+
+func (p *PluginRow) Values() []any {
+	return []any{p.Module, p.Version, p.Sum, p.Replace}
+}
+
+// RowEncoder writes rows in one of the machine-readable formats. The
+// ndjson encoder writes every row directly, the others collect the rows
+// and write them sorted (by the columns in order) when closed, so the
+// output does not depend on the order the providers respond in.
+type RowEncoder interface {
+	Encode(row Row) error
+	Close() error
+}
+
+func NewRowEncoder(format Format, writer io.Writer, columns []string) RowEncoder {
+
+	if format == FormatNDJSON {
+		return &ndjsonEncoder{writer: writer}
+	}
+
+	return &sortedEncoder{format: format, writer: writer, columns: columns}
+}
+
+type ndjsonEncoder struct {
+	writer io.Writer
+	lock   sync.Mutex
+}
+
+func (n *ndjsonEncoder) Encode(row Row) error {
+
+	data, err := json.Marshal(row)
+
+	if err != nil {
+		return err
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	_, err = n.writer.Write(append(data, '\n'))
+
+	return err
+}
+
+func (n *ndjsonEncoder) Close() error {
+	return nil
+}
+
+type sortedEncoder struct {
+	format  Format
+	writer  io.Writer
+	columns []string
+	rows    []Row
+	lock    sync.Mutex
+}
+
+func (s *sortedEncoder) Encode(row Row) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.rows = append(s.rows, row)
+
+	return nil
+}
+
+func (s *sortedEncoder) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	slices.SortStableFunc(s.rows, compareRows)
+
+	switch s.format {
+	case FormatJSON:
+		return s.writeJSON()
+	case FormatCSV:
+		return s.writeCSV()
+	case FormatYAML:
+		return s.writeYAML()
+	default:
+		return fmt.Errorf("unsupported format %q", s.format)
+	}
+}
+
+func (s *sortedEncoder) writeJSON() error {
+
+	var rows = s.rows
+
+	if nil == rows {
+		rows = make([]Row, 0)
+	}
+
+	data, err := json.MarshalIndent(rows, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	_, err = s.writer.Write(append(data, '\n'))
+
+	return err
+}
+
+func (s *sortedEncoder) writeCSV() error {
+
+	var writer = csv.NewWriter(s.writer)
+
+	if err := writer.Write(s.columns); err != nil {
+		return err
+	}
+
+	for _, row := range s.rows {
+
+		var values = row.Values()
+		var record = make([]string, len(values))
+
+		for i, c := 0, len(values); i < c; i++ {
+			record[i] = fmt.Sprint(values[i])
+		}
+
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// writeYAML writes the rows as a list of mappings, strings are always
+// double-quoted so values like "yes", "null" or "1e3" keep their type.
+func (s *sortedEncoder) writeYAML() error {
+
+	if len(s.rows) == 0 {
+		_, err := io.WriteString(s.writer, "[]\n")
+		return err
+	}
+
+	var buf strings.Builder
+
+	for _, row := range s.rows {
+		for i, value := range row.Values() {
+
+			if i == 0 {
+				buf.WriteString("- ")
+			} else {
+				buf.WriteString("  ")
+			}
+
+			buf.WriteString(s.columns[i])
+			buf.WriteString(": ")
+
+			switch x := value.(type) {
+			case string:
+				buf.WriteString(strconv.Quote(x))
+			default:
+				buf.WriteString(fmt.Sprint(x))
+			}
+
+			buf.WriteByte('\n')
+		}
+	}
+
+	_, err := io.WriteString(s.writer, buf.String())
+
+	return err
+}
+
+func compareRows(a, b Row) int {
+
+	var x, y = a.Values(), b.Values()
+
+	for i, c := 0, min(len(x), len(y)); i < c; i++ {
+
+		var ret int
+
+		switch v := x[i].(type) {
+		case string:
+			ret = strings.Compare(v, y[i].(string))
+		case int64:
+			ret = cmp.Compare(v, y[i].(int64))
+		}
+
+		if ret != 0 {
+			return ret
+		}
+	}
+
+	return cmp.Compare(len(x), len(y))
+}
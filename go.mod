@@ -6,4 +6,5 @@ require (
 	github.com/libdns/libdns v1.1.1
 	github.com/pbergman/logger v0.0.0-20251016100259-cad2d8840a7c
 	github.com/pbergman/provider v1.0.0
+	golang.org/x/crypto v0.48.0
 )
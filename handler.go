@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -9,6 +10,28 @@ import (
 	"github.com/pbergman/logger"
 )
 
+type contextKey int
+
+const (
+	userContextKey contextKey = iota
+)
+
+// withUser returns a copy of the request with the authenticated user
+func withUser(request *http.Request, user *User) *http.Request {
+	return request.WithContext(context.WithValue(request.Context(), userContextKey, user))
+}
+
+// getUser returns the authenticated user of the request, which is
+// nil when the server has no authentication configured.
+func getUser(request *http.Request) *User {
+
+	if user, ok := request.Context().Value(userContextKey).(*User); ok {
+		return user
+	}
+
+	return nil
+}
+
 type HandleResult bool
 
 const (
@@ -21,6 +44,20 @@ type Handler interface {
 	Handle(response http.ResponseWriter, request *http.Request) HandleResult
 }
 
+// RequestAwareHandler is a handler that derives a new request (like one
+// with the authenticated user) which is passed to the next handlers.
+type RequestAwareHandler interface {
+	Handler
+	HandleRequest(response http.ResponseWriter, request *http.Request) (*http.Request, HandleResult)
+}
+
+// RoleAwareHandler is a handler that requires the authenticated
+// user to have a specific role for handling the request.
+type RoleAwareHandler interface {
+	Handler
+	Role(request *http.Request) Role
+}
+
 type ResponseWriter struct {
 	http.ResponseWriter
 	status int
@@ -31,24 +68,70 @@ func (r *ResponseWriter) WriteHeader(status int) {
 	r.ResponseWriter.WriteHeader(status)
 }
 
+func (r *ResponseWriter) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
 func NewServerHandler(config *ServerConfig, logger *logger.Logger, plugins []PluginProvider) *ServerHandler {
 
 	var updateConfig *ServerUpdateConfig
+	var limiter *Limiter
+	var challengeZone string
 	var handlers = []Handler{
 		NewIconHandler(),
 	}
 
 	if nil != config {
 
-		if nil != config.Users {
-			handlers = append(handlers, NewAuthHandler(config.Users))
+		updateConfig = &config.ServerUpdateConfig
+		limiter = NewLimiter(config.RateLimit, logger)
+		challengeZone = config.ChallengeZone
+
+		var authenticators []Authenticator
+
+		if nil != config.Users || "" != config.UsersFile {
+
+			var file *PasswdFile
+
+			if "" != config.UsersFile {
+				file = NewPasswdFile(config.UsersFile, logger)
+			}
+
+			authenticators = append(authenticators, NewUserStore(config.Users, file))
 		}
 
-		updateConfig = &config.ServerUpdateConfig
+		if len(config.Tokens) > 0 {
+			authenticators = append(authenticators, NewTokenStore(config.Tokens))
+		}
+
+		if nil != config.JWT && "" != config.JWT.JWKS {
+			authenticators = append(authenticators, NewJWTAuthenticator(config.JWT, config.Users, logger))
+		}
+
+		if config.Hosts.HasSecrets() {
+			authenticators = append(authenticators, NewSignatureAuthenticator(updateConfig))
+		}
+
+		if nil != config.TLS && "" != config.TLS.ClientCA {
+			authenticators = append(authenticators, NewCertificateAuthenticator(config.Users))
+		}
+
+		if nil != config.ProxyAuth {
+			authenticators = append(authenticators, NewProxyAuthenticator(config.ProxyAuth, config.Users, config.TrustedRemotes))
+		}
+
+		if len(authenticators) > 0 {
+			handlers = append(handlers, NewAuthHandler(authenticators, updateConfig, limiter))
+		}
 	}
 
-	handlers = append(handlers, NewUpdateHandler(plugins, logger, updateConfig))
-	handlers = append(handlers, NewPrintHandler(plugins, logger))
+	var resolver = NewZoneResolver(plugins)
+
+	handlers = append(handlers, NewUpdateHandler(resolver, logger, updateConfig, limiter))
+	handlers = append(handlers, NewLimitHandler(limiter))
+	handlers = append(handlers, NewACMEHandler(resolver, logger, challengeZone))
+	handlers = append(handlers, NewApiHandler(resolver, logger))
+	handlers = append(handlers, NewPrintHandler(resolver, logger))
 
 	return &ServerHandler{logger: logger, handlers: handlers}
 }
@@ -65,11 +148,29 @@ func (h *ServerHandler) log(start time.Time, response *ResponseWriter, request *
 		uri = request.URL.RequestURI()
 	}
 
+	// don't leak tokens in the logs
+	if query := request.URL.Query(); query.Has("token") {
+		query.Set("token", "***")
+		uri = request.URL.Path + "?" + query.Encode()
+	}
+
 	h.logger.Debug(fmt.Sprintf(
 		"%s \"%s HTTP/%d.%d\" %d %s", request.Method, uri, request.ProtoMajor, request.ProtoMinor, response.status, time.Now().Sub(start).Round(time.Millisecond),
 	))
 }
 
+// isGranted checks if the authenticated user has the role required by
+// the handler, when no user is authenticated (because the server has no
+// authentication configured) everything is granted.
+func (h *ServerHandler) isGranted(handler Handler, request *http.Request) bool {
+
+	if x, ok := handler.(RoleAwareHandler); ok {
+		return getUser(request).HasRole(x.Role(request))
+	}
+
+	return true
+}
+
 func (h *ServerHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
 
 	var resp = &ResponseWriter{response, 200}
@@ -79,7 +180,21 @@ func (h *ServerHandler) ServeHTTP(response http.ResponseWriter, request *http.Re
 
 	for i, c := 0, len(h.handlers); i < c; i++ {
 		if h.handlers[i].Supports(request.URL) {
-			if StopPropagation == h.handlers[i].Handle(resp, request) {
+
+			if false == h.isGranted(h.handlers[i], request) {
+				denyRequest(resp, request, http.StatusForbidden)
+				return
+			}
+
+			var result HandleResult
+
+			if x, ok := h.handlers[i].(RequestAwareHandler); ok {
+				request, result = x.HandleRequest(resp, request)
+			} else {
+				result = h.handlers[i].Handle(resp, request)
+			}
+
+			if StopPropagation == result {
 				return
 			}
 		}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/libdns/libdns"
+	"github.com/pbergman/logger"
+)
+
+func NewACMEHandler(resolver *ZoneResolver, logger *logger.Logger, zone string) Handler {
+	return &ACMEHandler{
+		resolver: resolver,
+		logger:   logger,
+		zone:     strings.ToLower(strings.TrimSuffix(zone, ".")),
+		values:   make(map[string][]string),
+		created:  make(map[string][]string),
+	}
+}
+
+// ACMEHandler manages dns-01 challenge records for other ACME clients with
+// the api of the lego httpreq provider (/present and /cleanup) and the
+// acme-dns api (/update).
+type ACMEHandler struct {
+	resolver *ZoneResolver
+	logger   *logger.Logger
+	// zone for acme-dns subdomains that are not a fqdn
+	zone string
+	lock sync.Mutex
+	// the last two values per acme-dns record, newest last
+	values map[string][]string
+	// the values per acme-dns record added by /update that are not removed yet
+	created map[string][]string
+}
+
+// challengeRequest is the body of the httpreq requests, which in the default
+// mode holds the fqdn and value and in raw mode the domain and key auth.
+type challengeRequest struct {
+	FQDN    string `json:"fqdn"`
+	Value   string `json:"value"`
+	Domain  string `json:"domain"`
+	KeyAuth string `json:"keyAuth"`
+	// acme-dns fields
+	Subdomain string `json:"subdomain"`
+	Txt       string `json:"txt"`
+}
+
+func (a *ACMEHandler) Supports(url *url.URL) bool {
+	return url.Path == "/present" || url.Path == "/cleanup" || url.Path == "/update"
+}
+
+func (a *ACMEHandler) Role(_ *http.Request) Role {
+	return RoleACME
+}
+
+func (a *ACMEHandler) Handle(response http.ResponseWriter, request *http.Request) HandleResult {
+
+	if request.Method != http.MethodPost {
+		http.Error(response, "method not allowed", http.StatusMethodNotAllowed)
+		return StopPropagation
+	}
+
+	var body challengeRequest
+
+	if err := json.NewDecoder(http.MaxBytesReader(response, request.Body, 1<<16)).Decode(&body); err != nil {
+		http.Error(response, "invalid request body", http.StatusBadRequest)
+		return StopPropagation
+	}
+
+	name, value, err := a.getRecord(request.URL.Path, &body)
+
+	if err != nil {
+		http.Error(response, err.Error(), http.StatusBadRequest)
+		return StopPropagation
+	}
+
+	zone, err := resolveZone(request.Context(), a.resolver, name)
+
+	if err != nil {
+		a.logger.Debug(fmt.Sprintf("no zone for challenge record %s: %s", name, err.Error()))
+		http.Error(response, fmt.Sprintf("no zone found for '%s'", name), http.StatusNotFound)
+		return StopPropagation
+	}
+
+	if user := getUser(request); false == user.AllowsHost(strings.TrimPrefix(name, "_acme-challenge."), zone.Name) {
+		a.logger.Debug(fmt.Sprintf("user %s is not allowed to manage challenge record %s", user.Name, name))
+		http.Error(response, fmt.Sprintf("not allowed to manage '%s'", name), http.StatusForbidden)
+		return StopPropagation
+	}
+
+	switch request.URL.Path {
+	case "/present":
+		err = appendChallengeRecord(request.Context(), zone, name, value)
+	case "/cleanup":
+		err = deleteChallengeRecord(request.Context(), zone, name, value)
+	default:
+		err = a.update(request, zone, name, value)
+	}
+
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("failed to %s challenge record %s: %s", request.URL.Path[1:], name, err.Error()))
+		http.Error(response, "failed to update challenge record", http.StatusInternalServerError)
+		return StopPropagation
+	}
+
+	a.logger.Debug(fmt.Sprintf("%s challenge record %s (zone %s, module %s)", request.URL.Path[1:], name, zone.Name, zone.Plugin.Module().Path))
+
+	if request.URL.Path == "/update" {
+		response.Header().Set("content-type", "application/json")
+		_ = json.NewEncoder(response).Encode(map[string]string{"txt": value})
+	}
+
+	return StopPropagation
+}
+
+// getRecord returns the name and value of the challenge record from the
+// request, where for raw httpreq requests the value is computed from the
+// key authorization and the acme-dns subdomain can be a fqdn (for which
+// the _acme-challenge record is updated) or a label in the configured zone.
+func (a *ACMEHandler) getRecord(path string, body *challengeRequest) (string, string, error) {
+
+	var name, value string
+
+	switch {
+	case path == "/update":
+		if body.Subdomain == "" || body.Txt == "" {
+			return "", "", errors.New("missing subdomain or txt")
+		}
+
+		if strings.Contains(strings.TrimSuffix(body.Subdomain, "."), ".") {
+			name = challengeName(body.Subdomain)
+		} else if a.zone != "" {
+			name = strings.ToLower(body.Subdomain) + "." + a.zone
+		} else {
+			return "", "", errors.New("subdomain should be a fully qualified domain name")
+		}
+
+		value = body.Txt
+	case body.FQDN != "":
+		name, value = strings.ToLower(strings.TrimSuffix(body.FQDN, ".")), body.Value
+	case body.Domain != "":
+		var sum = sha256.Sum256([]byte(body.KeyAuth))
+		name, value = challengeName(body.Domain), base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+
+	if name == "" || value == "" {
+		return "", "", errors.New("missing fqdn and value (or domain and keyAuth)")
+	}
+
+	if false == isFqdn(name) {
+		return "", "", fmt.Errorf("'%s' is not a valid record name", name)
+	}
+
+	// only acme-dns subdomains can be records in the challenge zone
+	if path != "/update" && false == strings.HasPrefix(name, "_acme-challenge.") {
+		return "", "", errors.New("only _acme-challenge records can be managed")
+	}
+
+	return name, value, nil
+}
+
+// update adds the value for an acme-dns subdomain, where like acme-dns
+// only the last two values (for a domain and its wildcard) are kept. Only
+// values added by this endpoint are removed, so records at the same name
+// from /present (or other clients) are left alone. Which values were added
+// is kept in memory, so values from before a restart are not removed.
+func (a *ACMEHandler) update(request *http.Request, zone *ZoneEntry, name, value string) error {
+
+	// registered before the value is added, so a concurrent update
+	// that sees the value in the zone knows it should be kept
+	a.lock.Lock()
+	a.values[name] = append(a.values[name], value)
+
+	if len(a.values[name]) > 2 {
+		a.values[name] = a.values[name][len(a.values[name])-2:]
+	}
+
+	if false == slices.Contains(a.created[name], value) {
+		a.created[name] = append(a.created[name], value)
+	}
+
+	a.lock.Unlock()
+
+	if err := appendChallengeRecord(request.Context(), zone, name, value); err != nil {
+		return err
+	}
+
+	records, err := zone.Plugin.GetRecords(request.Context(), zone.Name)
+
+	if err != nil {
+		return err
+	}
+
+	a.lock.Lock()
+	var keep = append([]string{value}, a.values[name]...)
+	var created = slices.Clone(a.created[name])
+	a.lock.Unlock()
+
+	var relative = zone.RelativeName(name)
+	var stale = make([]libdns.Record, 0)
+	var removed = make([]string, 0)
+
+	for _, record := range records {
+		if rr := record.RR(); rr.Type == "TXT" && strings.EqualFold(rr.Name, relative) && slices.Contains(created, rr.Data) && false == slices.Contains(keep, rr.Data) {
+			stale = append(stale, libdns.TXT{Name: relative, Text: rr.Data})
+			removed = append(removed, rr.Data)
+		}
+	}
+
+	if len(stale) > 0 {
+		if _, err := zone.Plugin.DeleteRecords(request.Context(), zone.Name, stale); err != nil {
+			return err
+		}
+
+		a.lock.Lock()
+		a.created[name] = slices.DeleteFunc(a.created[name], func(x string) bool {
+			return slices.Contains(removed, x) && false == slices.Contains(a.values[name], x)
+		})
+		a.lock.Unlock()
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestACMEHandlerUpdate(t *testing.T) {
+
+	var provider = newTestProvider("test", "example.com")
+	var zone = &ZoneEntry{Name: "example.com", Plugin: provider}
+	var handler = NewACMEHandler(NewZoneResolver([]PluginProvider{provider}), testLogger(), "").(*ACMEHandler)
+	var name = "_acme-challenge.www.example.com"
+
+	if err := appendChallengeRecord(context.Background(), zone, name, "present"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, value := range []string{"a", "b", "c", "d"} {
+		if err := handler.update(httptest.NewRequest("POST", "/update", nil), zone, name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var values = make([]string, 0)
+
+	for _, record := range provider.records["example.com"] {
+		values = append(values, record.RR().Data)
+	}
+
+	slices.Sort(values)
+
+	// the value of /present is not removed by the rotation
+	if false == slices.Equal(values, []string{"c", "d", "present"}) {
+		t.Errorf("unexpected values %v", values)
+	}
+
+	if created := handler.created[name]; false == slices.Equal(created, []string{"c", "d"}) {
+		t.Errorf("unexpected created values %v", created)
+	}
+}
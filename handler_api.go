@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/libdns/libdns"
+	"github.com/pbergman/logger"
+)
+
+const apiPrefix = "/api/v1/zones"
+
+func NewApiHandler(resolver *ZoneResolver, logger *logger.Logger) Handler {
+	return &ApiHandler{
+		resolver: resolver,
+		logger:   logger,
+	}
+}
+
+// ApiHandler is a json api for listing zones and managing the records of
+// a zone, which maps the http methods on the records to the provider:
+//
+//	GET    /api/v1/zones                   ListZones
+//	GET    /api/v1/zones/{zone}/records    GetRecords
+//	POST   /api/v1/zones/{zone}/records    AppendRecords
+//	PUT    /api/v1/zones/{zone}/records    SetRecords
+//	DELETE /api/v1/zones/{zone}/records    DeleteRecords
+type ApiHandler struct {
+	resolver *ZoneResolver
+	logger   *logger.Logger
+}
+
+// ApiZone is the json representation of a zone
+type ApiZone struct {
+	Name     string `json:"name"`
+	Module   string `json:"module"`
+	Priority int    `json:"priority"`
+}
+
+// ApiRecord is the json representation of a record, where the name is
+// relative to the zone and the TTL in seconds.
+type ApiRecord struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	TTL  int64  `json:"ttl"`
+	Data string `json:"data"`
+}
+
+func NewApiRecord(record libdns.Record) *ApiRecord {
+	var rr = record.RR()
+
+	return &ApiRecord{
+		Name: rr.Name,
+		Type: rr.Type,
+		TTL:  int64(rr.TTL / time.Second),
+		Data: rr.Data,
+	}
+}
+
+// Record returns the record for given zone, when parse is true the record
+// is validated and converted to the libdns type of the record.
+func (a *ApiRecord) Record(zone string, parse bool) (libdns.Record, error) {
+
+	var rr = libdns.RR{
+		Name: apiRecordName(a.Name, zone),
+		Type: strings.ToUpper(a.Type),
+		TTL:  time.Duration(a.TTL) * time.Second,
+		Data: a.Data,
+	}
+
+	if false == parse {
+		return rr, nil
+	}
+
+	if rr.Type == "" || rr.Data == "" {
+		return nil, fmt.Errorf("record '%s' requires a type and data", a.Name)
+	}
+
+	return rr.Parse()
+}
+
+// apiRecordName returns the name relative to the zone, where a name with
+// a trailing dot is absolute and an empty name or @ is the zone apex.
+func apiRecordName(name, zone string) string {
+
+	if name == "" || name == "@" {
+		return "@"
+	}
+
+	if strings.HasSuffix(name, ".") {
+		return libdns.RelativeName(strings.ToLower(name), strings.ToLower(fqdn(zone)))
+	}
+
+	return name
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func writeApiResponse(response http.ResponseWriter, status int, v any) {
+	response.Header().Set("content-type", "application/json")
+	response.WriteHeader(status)
+
+	_ = json.NewEncoder(response).Encode(v)
+}
+
+func writeApiError(response http.ResponseWriter, status int, err error) {
+	writeApiResponse(response, status, &apiError{Error: err.Error()})
+}
+
+func (a *ApiHandler) Supports(url *url.URL) bool {
+	return url.Path == apiPrefix || strings.HasPrefix(url.Path, apiPrefix+"/")
+}
+
+func (a *ApiHandler) Role(request *http.Request) Role {
+
+	if request.Method == http.MethodGet || request.Method == http.MethodHead {
+		return RoleRead
+	}
+
+	return RoleAdmin
+}
+
+func (a *ApiHandler) Handle(response http.ResponseWriter, request *http.Request) HandleResult {
+
+	table, err := a.resolver.Fetch(request.Context(), NewSemaphore(5))
+
+	if err != nil {
+		a.logger.Error(err.Error())
+	}
+
+	if request.URL.Path == apiPrefix {
+
+		if request.Method != http.MethodGet {
+			writeApiError(response, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", request.Method))
+			return StopPropagation
+		}
+
+		var zones = make([]*ApiZone, 0)
+
+		for _, entry := range table.Entries() {
+			zones = append(zones, &ApiZone{Name: entry.Name, Module: entry.Plugin.Module().Path, Priority: entry.Plugin.Priority()})
+		}
+
+		writeApiResponse(response, http.StatusOK, zones)
+		return StopPropagation
+	}
+
+	zone, resource, _ := strings.Cut(strings.TrimPrefix(request.URL.Path, apiPrefix+"/"), "/")
+
+	if resource != "records" {
+		writeApiError(response, http.StatusNotFound, errors.New("not found"))
+		return StopPropagation
+	}
+
+	entry, err := a.getZone(table, zone, request.URL.Query().Get("module"))
+
+	if err != nil {
+		writeApiError(response, http.StatusNotFound, err)
+		return StopPropagation
+	}
+
+	switch request.Method {
+	case http.MethodGet:
+		a.getRecords(response, request, entry)
+	case http.MethodPost, http.MethodPut, http.MethodDelete:
+		a.writeRecords(response, request, entry)
+	default:
+		writeApiError(response, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", request.Method))
+	}
+
+	return StopPropagation
+}
+
+// getZone returns the (preferred) plugin for the zone, or the
+// plugin of given module when multiple plugins serve the zone.
+func (a *ApiHandler) getZone(table *ZoneTable, zone, module string) (*ZoneEntry, error) {
+
+	zone, _ = url.PathUnescape(zone)
+
+	for _, entry := range table.Lookup(zone) {
+		if module == "" || entry.Plugin.Module().Path == module {
+			return entry, nil
+		}
+	}
+
+	return nil, fmt.Errorf("zone '%s' not found", zone)
+}
+
+// filterRecords returns the records matching the name and type filter
+func filterRecords(records []libdns.Record, zone, name, rtype string) []libdns.Record {
+
+	var filtered = make([]libdns.Record, 0, len(records))
+
+	for _, record := range records {
+		var rr = record.RR()
+
+		if name != "" && false == strings.EqualFold(rrKey(rr.Name, "", zone), rrKey(apiRecordName(name, zone), "", zone)) {
+			continue
+		}
+
+		if rtype != "" && false == strings.EqualFold(rr.Type, rtype) {
+			continue
+		}
+
+		filtered = append(filtered, record)
+	}
+
+	return filtered
+}
+
+func (a *ApiHandler) getRecords(response http.ResponseWriter, request *http.Request, zone *ZoneEntry) {
+
+	records, err := zone.Plugin.GetRecords(request.Context(), zone.Name)
+
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("%s: failed to get records for zone %s: %s", zone.Plugin.Module().Path, zone.Name, err.Error()))
+		writeApiError(response, http.StatusBadGateway, err)
+		return
+	}
+
+	var query = request.URL.Query()
+	var result = make([]*ApiRecord, 0)
+
+	for _, record := range filterRecords(records, zone.Name, query.Get("name"), query.Get("type")) {
+		result = append(result, NewApiRecord(record))
+	}
+
+	writeApiResponse(response, http.StatusOK, result)
+}
+
+// readRecords reads the records from the request body, or for delete requests
+// without body, the records matching the name and type from the query.
+func (a *ApiHandler) readRecords(request *http.Request, zone *ZoneEntry) ([]libdns.Record, error) {
+
+	var items []*ApiRecord
+
+	if err := json.NewDecoder(io.LimitReader(request.Body, 1<<20)).Decode(&items); err != nil {
+
+		if false == errors.Is(err, io.EOF) || request.Method != http.MethodDelete {
+			return nil, fmt.Errorf("invalid request body: %w", err)
+		}
+
+		var query = request.URL.Query()
+
+		if query.Get("name") == "" && query.Get("type") == "" {
+			return nil, errors.New("delete requires records or a name or type filter")
+		}
+
+		records, err := zone.Plugin.GetRecords(request.Context(), zone.Name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return filterRecords(records, zone.Name, query.Get("name"), query.Get("type")), nil
+	}
+
+	var records = make([]libdns.Record, len(items))
+
+	for i, item := range items {
+
+		record, err := item.Record(zone.Name, request.Method != http.MethodDelete)
+
+		if err != nil {
+			return nil, err
+		}
+
+		records[i] = record
+	}
+
+	return records, nil
+}
+
+func (a *ApiHandler) writeRecords(response http.ResponseWriter, request *http.Request, zone *ZoneEntry) {
+
+	records, err := a.readRecords(request, zone)
+
+	if err != nil {
+		writeApiError(response, http.StatusBadRequest, err)
+		return
+	}
+
+	var user = getUser(request)
+
+	for _, record := range records {
+		if name := libdns.AbsoluteName(record.RR().Name, zone.Name); false == user.AllowsHost(name, zone.Name) {
+			writeApiError(response, http.StatusForbidden, fmt.Errorf("not allowed to change '%s'", name))
+			return
+		}
+	}
+
+	var status = http.StatusOK
+	var call func(context.Context, string, []libdns.Record) ([]libdns.Record, error)
+
+	switch request.Method {
+	case http.MethodPost:
+		call, status = zone.Plugin.AppendRecords, http.StatusCreated
+	case http.MethodPut:
+		call = zone.Plugin.SetRecords
+	default:
+		call = zone.Plugin.DeleteRecords
+	}
+
+	result, err := call(request.Context(), zone.Name, records)
+
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("%s: failed to %s records in zone %s: %s", zone.Plugin.Module().Path, strings.ToLower(request.Method), zone.Name, err.Error()))
+		writeApiError(response, http.StatusBadGateway, err)
+		return
+	}
+
+	var items = make([]*ApiRecord, 0, len(result))
+
+	for _, record := range result {
+		items = append(items, NewApiRecord(record))
+	}
+
+	writeApiResponse(response, status, items)
+}
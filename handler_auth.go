@@ -1,18 +1,38 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
-func NewAuthHandler(users *UserList) Handler {
+// Authenticator resolves the user for a request with one of the
+// supported authentication schemes.
+type Authenticator interface {
+	// Authenticate returns the user for the request, or nil when the
+	// request has no credentials for this scheme and an error when the
+	// request has credentials that are invalid.
+	Authenticate(request *http.Request) (*User, error)
+	// Challenge returns the value for the WWW-Authenticate header
+	Challenge() string
+}
+
+func NewAuthHandler(authenticators []Authenticator, config *ServerUpdateConfig, limiter *Limiter) Handler {
 	return &AuthenticationHandler{
-		users: users,
+		authenticators: authenticators,
+		config:         config,
+		limiter:        limiter,
 	}
 }
 
 type AuthenticationHandler struct {
-	users *UserList
+	authenticators []Authenticator
+	config         *ServerUpdateConfig
+	limiter        *Limiter
 }
 
 func (u *AuthenticationHandler) Supports(_ *url.URL) bool {
@@ -20,14 +40,102 @@ func (u *AuthenticationHandler) Supports(_ *url.URL) bool {
 }
 
 func (u *AuthenticationHandler) Handle(response http.ResponseWriter, request *http.Request) HandleResult {
+	_, result := u.HandleRequest(response, request)
+	return result
+}
+
+// HandleRequest authenticates the request and returns the request with the
+// authenticated user, which is passed to the handlers after this one.
+func (u *AuthenticationHandler) HandleRequest(response http.ResponseWriter, request *http.Request) (*http.Request, HandleResult) {
+
+	var user *User
+	var err error
+	var keys = u.limitKeys(request)
+
+	if wait, locked := u.limiter.Locked(keys...); locked {
+		response.Header().Set("Retry-After", strconv.Itoa(int(wait.Round(time.Second).Seconds())))
+
+		if request.URL.Path == "/nic/update" {
+			writeUpdateError(response, request, NewUpdateError(UpdateAbuse, fmt.Errorf("too many failed authentications, retry in %s", wait.Round(time.Second))))
+		} else {
+			http.Error(response, "", http.StatusTooManyRequests)
+		}
+
+		return request, StopPropagation
+	}
+
+	for _, authenticator := range u.authenticators {
+		if user, err = authenticator.Authenticate(request); err != nil || nil != user {
+			break
+		}
+	}
+
+	if nil == user || false == u.allowsSource(user, request) {
+
+		if err != nil || nil != user {
+			u.limiter.Fail(keys...)
+		}
+
+		var challenges = make(map[string]bool)
+
+		for _, authenticator := range u.authenticators {
+			if challenge := authenticator.Challenge(); challenge != "" && false == challenges[challenge] {
+				challenges[challenge] = true
+				response.Header().Add("WWW-Authenticate", challenge)
+			}
+		}
+
+		denyRequest(response, request, http.StatusUnauthorized)
+		return request, StopPropagation
+	}
+
+	u.limiter.Reset(keys...)
+
+	return withUser(request, user), ContinuePropagation
+}
+
+// denyRequest writes an unauthorized (401) or forbidden (403) response,
+// where update clients get the badauth return code they expect.
+func denyRequest(response http.ResponseWriter, request *http.Request, status int) {
+
+	if request.URL.Path == "/nic/update" {
+		writeUpdateError(response, request, NewUpdateError(UpdateBadAuth, errors.New(strings.ToLower(http.StatusText(status)))))
+		return
+	}
+
+	http.Error(response, "", status)
+}
+
+// limitKeys returns the keys for the limiter, which are the client
+// address and the name of the user when credentials are given.
+func (u *AuthenticationHandler) limitKeys(request *http.Request) []string {
+
+	var keys = make([]string, 0, 2)
+
+	if addr, err := getClientAddr(request.RemoteAddr, request.Header, u.config); err == nil {
+		keys = append(keys, "ip:"+addr.String())
+	} else {
+		keys = append(keys, "ip:"+request.RemoteAddr)
+	}
+
+	if name, _, ok := getCredentials(request); ok {
+		keys = append(keys, "user:"+name)
+	}
+
+	return keys
+}
+
+func (u *AuthenticationHandler) allowsSource(user *User, request *http.Request) bool {
+
+	if nil == user.Sources {
+		return true
+	}
 
-	user, passwd, ok := request.BasicAuth()
+	addr, err := getClientAddr(request.RemoteAddr, request.Header, u.config)
 
-	if false == ok || false == u.users.Authenticate(user, passwd) {
-		response.Header().Add("WWW-Authenticate", `Basic realm="DDNS Server"`)
-		http.Error(response, "", http.StatusUnauthorized)
-		return StopPropagation
+	if err != nil {
+		return false
 	}
 
-	return ContinuePropagation
+	return user.AllowsSource(addr)
 }
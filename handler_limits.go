@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+func NewLimitHandler(limiter *Limiter) Handler {
+	return &LimitHandler{
+		limiter: limiter,
+	}
+}
+
+// LimitHandler prints the counters of the limiter, so
+// it is possible to see which clients are throttled.
+type LimitHandler struct {
+	limiter *Limiter
+}
+
+func (l *LimitHandler) Supports(url *url.URL) bool {
+	return url.Path == "/limits"
+}
+
+func (l *LimitHandler) Role(_ *http.Request) Role {
+	return RoleAdmin
+}
+
+func (l *LimitHandler) Handle(response http.ResponseWriter, _ *http.Request) HandleResult {
+
+	response.Header().Set("content-type", "application/json")
+
+	var encoder = json.NewEncoder(response)
+
+	encoder.SetIndent("", "  ")
+
+	_ = encoder.Encode(l.limiter.Status())
+
+	return StopPropagation
+}
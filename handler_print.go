@@ -9,38 +9,57 @@ import (
 	"github.com/pbergman/logger"
 )
 
-func NewPrintHandler(plugins []PluginProvider, logger *logger.Logger) Handler {
+func NewPrintHandler(resolver *ZoneResolver, logger *logger.Logger) Handler {
 	return &PrintHandler{
-		plugins: plugins,
-		logger:  logger,
+		resolver: resolver,
+		logger:   logger,
 	}
 }
 
 type PrintHandler struct {
-	plugins []PluginProvider
-	logger  *logger.Logger
+	resolver *ZoneResolver
+	logger   *logger.Logger
 }
 
 func (p *PrintHandler) Supports(_ *url.URL) bool {
 	return true
 }
 
+func (p *PrintHandler) Role(_ *http.Request) Role {
+	return RoleRead
+}
+
 func (p *PrintHandler) Handle(response http.ResponseWriter, request *http.Request) (result HandleResult) {
 
-	response.Header().Set("content-type", "text/plain; charset=utf-8")
+	format, err := NegotiateFormat(request)
+
+	if err != nil {
+		http.Error(response, err.Error(), http.StatusBadRequest)
+		return StopPropagation
+	}
+
+	response.Header().Set("content-type", format.ContentType())
 
 	var lock = NewSemaphore(5)
 	var stdout = &Writer{
 		Writer: response,
 	}
 
-	var stderr = io.MultiWriter(stdout, &Writer{
+	var stderr io.Writer = &Writer{
 		Writer: p.logger.NewWriter(logger.Error),
-	})
+	}
+
+	switch format {
+	case FormatTable:
+		// errors are shown inline for the plain text output
+		stderr = io.MultiWriter(stdout, stderr)
+	case FormatNDJSON:
+		stdout.Writer = &flushWriter{response}
+	}
 
 	if request.URL.Path == "/zones" {
 
-		WriteZones(request.Context(), p.plugins, lock, stdout, stderr)
+		WriteZones(request.Context(), p.resolver, lock, format, stdout, stderr)
 
 	} else if strings.HasPrefix(request.URL.Path, "/lookup/") {
 
@@ -59,12 +78,29 @@ func (p *PrintHandler) Handle(response http.ResponseWriter, request *http.Reques
 			host = x
 		}
 
-		WriteShort(request.Context(), p.plugins, lock, stdout, stderr, strings.ToUpper(rtype), host)
+		WriteShort(request.Context(), p.resolver, lock, format, stdout, stderr, strings.ToUpper(rtype), host)
 
 	} else {
 
-		WriteRecords(request.Context(), p.plugins, lock, stdout, stderr)
+		WriteRecords(request.Context(), p.resolver, lock, format, stdout, stderr)
 	}
 
 	return StopPropagation
 }
+
+// flushWriter flushes the response after every write so streamed
+// rows reach the client without waiting for the other providers.
+type flushWriter struct {
+	http.ResponseWriter
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+
+	n, err := f.ResponseWriter.Write(p)
+
+	if err == nil {
+		_ = http.NewResponseController(f.ResponseWriter).Flush()
+	}
+
+	return n, err
+}
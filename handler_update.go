@@ -4,10 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io"
+	"maps"
 	"net/http"
 	"net/netip"
 	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -16,60 +18,30 @@ import (
 	"github.com/pbergman/logger"
 )
 
-type UpdateResult struct {
-	items []string
-	lock  sync.Mutex
-}
-
-func (u *UpdateResult) WriteTo(w io.Writer) (n int64, err error) {
-	u.lock.Lock()
-	defer u.lock.Unlock()
-
-	x, err := w.Write([]byte(strings.Join(u.items, "\n")))
-
-	if err != nil {
-		return int64(x), err
-	}
-
-	return int64(x), nil
-}
-
-func (u *UpdateResult) Set(idx int, value string) {
-	u.lock.Lock()
-	u.items[idx] = value
-	u.lock.Unlock()
-}
-
-func NewUpdateResult(size int, ip *netip.Addr) *UpdateResult {
-	var update = &UpdateResult{
-		items: make([]string, size),
-	}
-
-	for i := 0; i < size; i++ {
-		update.items[i] = fmt.Sprintf("nochg %s", ip)
-	}
-
-	return update
-}
-
-func NewUpdateHandler(plugins []PluginProvider, logger *logger.Logger, config *ServerUpdateConfig) Handler {
+func NewUpdateHandler(resolver *ZoneResolver, logger *logger.Logger, config *ServerUpdateConfig, limiter *Limiter) Handler {
 	return &UpdateHandler{
-		plugins: plugins,
-		logger:  logger,
-		config:  config,
+		resolver: resolver,
+		logger:   logger,
+		config:   config,
+		limiter:  limiter,
 	}
 }
 
 type UpdateHandler struct {
-	plugins []PluginProvider
-	logger  *logger.Logger
-	config  *ServerUpdateConfig
+	resolver *ZoneResolver
+	logger   *logger.Logger
+	config   *ServerUpdateConfig
+	limiter  *Limiter
 }
 
 func (u *UpdateHandler) Supports(url *url.URL) bool {
 	return url.Path == "/nic/update"
 }
 
+func (u *UpdateHandler) Role(_ *http.Request) Role {
+	return RoleUpdate
+}
+
 func (u *UpdateHandler) Handle(response http.ResponseWriter, request *http.Request) (status HandleResult) {
 
 	var query = request.URL.Query()
@@ -78,162 +50,540 @@ func (u *UpdateHandler) Handle(response http.ResponseWriter, request *http.Reque
 
 	status = StopPropagation
 
+	if err = u.checkAgent(request); err != nil {
+		writeUpdateError(response, request, err)
+		return
+	}
+
 	if hosts, err = u.getHosts(query); err != nil {
-		http.Error(response, err.Error(), http.StatusBadRequest)
+		writeUpdateError(response, request, err)
 		return
 	}
 
-	var ip netip.Addr
+	var addrs UpdateAddrs
+	var user = getUser(request)
+	var params = query
+
+	if nil != user && user.DetectedIpOnly {
+		params = make(url.Values)
+	}
+
+	if addrs, err = getIp(params, request.RemoteAddr, request.Header, u.config); err != nil {
 
-	if ip, err = getIp(query, request.RemoteAddr, request.Header, u.config); err != nil {
-		http.Error(response, err.Error(), http.StatusBadRequest)
+		var ref *UpdateError
+
+		if false == errors.As(err, &ref) {
+			u.logger.Error(fmt.Sprintf("failed to resolve ip for update: %s", err.Error()))
+			err = NewUpdateError(UpdateServErr, err)
+		}
+
+		writeUpdateError(response, request, err)
 		return
 	}
 
 	var lock = NewSemaphore(5)
-	var result = NewUpdateResult(len(hosts), &ip)
-	var zones [][]string
+	var result = NewUpdateResult(hosts)
+	var allowed = u.allowUpdates(hosts, result)
 
-	defer result.WriteTo(response)
-
-	if zones, err = u.fetchZones(request.Context(), lock); err != nil {
-		http.Error(response, err.Error(), http.StatusFailedDependency)
+	// the update interval is checked before anything is fetched, so clients
+	// that keep sending the same update do not reach the providers
+	if false == slices.Contains(allowed, true) {
+		writeUpdateResult(response, request, result)
 		return
 	}
 
-	for idx, items := range u.makeUpdateLists(hosts, ip, zones, result) {
+	// the table holds the zones of the plugins that could be listed
+	// and makeUpdateLists fails the hosts when a plugin failed
+	zones, err := u.resolver.Fetch(request.Context(), lock)
+
+	if err != nil {
+		u.logger.Error(err.Error())
+	}
+
+	for idx, items := range u.makeUpdateLists(hosts, addrs, u.getUpdateOptions(query, user), user, zones, allowed, result) {
 		lock.Lock()
-		go u.updateRecords(request.Context(), hosts, result, items, u.plugins[idx], &ip, lock)
+		go u.updateRecords(request.Context(), hosts, result, items, idx, u.resolver.Plugins()[idx], lock)
 	}
 
 	lock.Wait()
 
+	if mode := u.fanOut(); mode != "" {
+		result.Combine(mode)
+	}
+
+	// written (good) and unchanged (nochg) hosts keep the started interval
+	for idx, hostname := range hosts {
+		if code := result.Code(idx); allowed[idx] && code != UpdateGood && code != UpdateNoChange {
+			u.limiter.Release(hostname)
+		}
+	}
+
+	writeUpdateResult(response, request, result)
+
 	return
 }
 
+// allowUpdates checks (and starts) the update interval of the hosts and
+// sets abuse for the hosts that were updated within their interval.
+func (u *UpdateHandler) allowUpdates(hosts []string, result *UpdateResult) []bool {
+
+	var allowed = make([]bool, len(hosts))
+
+	for idx, hostname := range hosts {
+
+		if wait, ok := u.limiter.AllowUpdate(hostname, time.Duration(u.getPolicy(hostname).MinInterval)); false == ok {
+			result.SetError(idx, UpdateAbuse, fmt.Errorf("too many updates for '%s', retry in %s", hostname, wait.Round(time.Second)))
+			continue
+		}
+
+		allowed[idx] = true
+	}
+
+	return allowed
+}
+
+// checkAgent validates the request method and user agent, as the protocol
+// requires clients to identify themselves with a user agent.
+func (u *UpdateHandler) checkAgent(request *http.Request) error {
+
+	if request.Method != http.MethodGet && request.Method != http.MethodPost {
+		return NewUpdateError(UpdateBadAgent, fmt.Errorf("method %s not allowed", request.Method))
+	}
+
+	if strings.TrimSpace(request.UserAgent()) == "" {
+		return NewUpdateError(UpdateBadAgent, errors.New("missing user agent"))
+	}
+
+	return nil
+}
+
 func (u *UpdateHandler) getHosts(query url.Values) ([]string, error) {
 
-	if false == query.Has("hostname") {
-		return nil, errors.New("missing required hostname")
+	if false == query.Has("hostname") || strings.TrimSpace(query.Get("hostname")) == "" {
+		return nil, NewUpdateError(UpdateNotFqdn, errors.New("missing required hostname"))
+	}
+
+	var hosts = strings.Split(query.Get("hostname"), ",")
+
+	// https://help.dyn.com/remote-access-api/perform-update/
+	if len(hosts) > 20 {
+		return nil, NewUpdateError(UpdateNumHost, fmt.Errorf("too many hosts (%d) specified, maximum is 20", len(hosts)))
 	}
 
-	return strings.Split(query.Get("hostname"), ","), nil
+	for i, c := 0, len(hosts); i < c; i++ {
+		hosts[i] = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(hosts[i]), "."))
+	}
+
+	return hosts, nil
 }
 
-func (u *UpdateHandler) fetchZones(ctx context.Context, lock WaitableLocker) ([][]string, error) {
+// isFqdn validates that the hostname is a fully qualified
+// domain name, so at least two valid labels.
+func isFqdn(hostname string) bool {
 
-	var zones = make([][]string, len(u.plugins))
-	var errList = make([]error, 0)
+	if len(hostname) > 253 {
+		return false
+	}
 
-	for idx, plugin := range u.plugins {
+	var labels = strings.Split(hostname, ".")
 
-		lock.Lock()
+	if len(labels) < 2 {
+		return false
+	}
 
-		var err error
+	for _, label := range labels {
 
-		errList = append(errList, err)
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
 
-		go u.fetchZonesForModule(ctx, lock, idx, &zones, plugin, &err)
+		for i, c := 0, len(label); i < c; i++ {
+			if false == (label[i] >= 'a' && label[i] <= 'z' || label[i] >= '0' && label[i] <= '9' || label[i] == '-' || label[i] == '_') {
+				return false
+			}
+		}
 	}
 
-	lock.Wait()
+	return true
+}
 
-	return zones, errors.Join(errList...)
+// UpdateOptions holds the optional dyndns2 parameters of an update request
+//
+// see https://help.dyn.com/remote-access-api/perform-update/
+type UpdateOptions struct {
+	// Wildcard is ON, OFF or empty when the wildcard should not change
+	Wildcard string
+	// MX holds the mail exchanger for the host, where nil means no change
+	// and an empty string that the MX records should be removed.
+	MX *string
+	// BackMX will set the host as primary and the MX as backup
+	BackMX  bool
+	Offline bool
+	// TTL as requested by the client (when allowed for the user)
+	TTL time.Duration
 }
 
-func (u *UpdateHandler) fetchZonesForModule(ctx context.Context, lock sync.Locker, idx int, list *[][]string, provider ZoneAwareProvider, ref *error) {
+func (u *UpdateHandler) getUpdateOptions(query url.Values, user *User) *UpdateOptions {
 
-	defer lock.Unlock()
+	var options = new(UpdateOptions)
 
-	zones, err := provider.ListZones(ctx)
+	if nil != u.config && u.config.QueryTTL && query.Has("ttl") && user.AllowsTTL() {
+		if ttl, err := strconv.Atoi(query.Get("ttl")); err == nil && ttl > 0 {
+			options.TTL = time.Duration(ttl) * time.Second
+		} else if ttl, err := time.ParseDuration(query.Get("ttl")); err == nil && ttl > 0 {
+			options.TTL = ttl
+		}
+	}
 
-	if err != nil {
-		*ref = err
-		return
+	if x := strings.ToUpper(query.Get("wildcard")); x == "ON" || x == "OFF" {
+		options.Wildcard = x
+	}
+
+	if query.Has("mx") && false == strings.EqualFold(query.Get("mx"), "NOCHG") {
+		var mx = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(query.Get("mx")), "."))
+		options.MX = &mx
+	}
+
+	options.BackMX = strings.EqualFold(query.Get("backmx"), "YES")
+	options.Offline = strings.EqualFold(query.Get("offline"), "YES")
+
+	return options
+}
+
+// zoneUpdate holds the records that should be set and
+// the (stale) records that should be removed for a zone
+type zoneUpdate struct {
+	set    []libdns.Record
+	delete []libdns.Record
+	// keep the TTL of existing records
+	keep bool
+}
+
+func (u *UpdateHandler) fanOut() FanOutMode {
+
+	if nil == u.config {
+		return ""
+	}
+
+	return u.config.FanOut
+}
+
+// getZones returns the preferred zone for the hostname and, in fan-out
+// mode, the same zone of the other plugins that serve that zone.
+func (u *UpdateHandler) getZones(matches []*ZoneEntry) []*ZoneEntry {
+
+	if u.fanOut() == "" {
+		return matches[:1]
 	}
 
-	if nil == (*list)[idx] {
-		(*list)[idx] = make([]string, 0)
+	var zones = make([]*ZoneEntry, 0, len(matches))
+
+	for _, zone := range matches {
+		if strings.EqualFold(fqdn(zone.Name), fqdn(matches[0].Name)) {
+			zones = append(zones, zone)
+		}
 	}
 
-	for _, zone := range zones {
-		(*list)[idx] = append((*list)[idx], strings.TrimSuffix(zone.Name, "."))
+	return zones
+}
+
+func (u *UpdateHandler) getPolicy(hostname string) *HostPolicy {
+
+	if nil == u.config {
+		return &HostPolicy{}
 	}
+
+	return u.config.Hosts.Get(hostname)
 }
 
-func (u *UpdateHandler) makeUpdateLists(hosts []string, ip netip.Addr, zones [][]string, result *UpdateResult) map[int]map[string][]libdns.Record {
+// makeUpdateLists groups the records of the hosts per plugin and zone. When
+// listing the zones of a plugin failed, the hosts get dnserr as that plugin
+// could serve a more specific zone (or the same zone with a higher priority)
+// and the longest match of the table is not necessarily the right zone.
+func (u *UpdateHandler) makeUpdateLists(hosts []string, addrs UpdateAddrs, options *UpdateOptions, user *User, zones *ZoneTable, allowed []bool, result *UpdateResult) map[int]map[string]*zoneUpdate {
 
-	var updates = make(map[int]map[string][]libdns.Record)
+	var updates = make(map[int]map[string]*zoneUpdate)
 
-hostnames:
 	for idx, hostname := range hosts {
 
+		// updated within the interval, see allowUpdates
+		if false == allowed[idx] {
+			continue
+		}
+
+		if false == isFqdn(hostname) {
+			result.SetError(idx, UpdateNotFqdn, fmt.Errorf("'%s' is not a fully qualified domain name", hostname))
+			continue
+		}
+
+		if nil != options.MX && *options.MX != "" && false == isFqdn(*options.MX) {
+			result.SetError(idx, UpdateNotFqdn, fmt.Errorf("mx '%s' is not a fully qualified domain name", *options.MX))
+			continue
+		}
+
+		if failed := zones.Failed(); len(failed) > 0 {
+			u.setFailedPlugins(idx, hostname, failed, result)
+			continue
+		}
+
 		u.logger.Debug(fmt.Sprintf("lookup provider for hostname '%s'", hostname))
 
-		for x, plugin := range u.plugins {
+		var matches = zones.Resolve(hostname)
 
-			for _, zone := range zones[x] {
-				if strings.HasSuffix(hostname, "."+zone) {
+		if len(matches) == 0 {
+			u.logger.Debug(fmt.Sprintf("hostname %s is not supported by any module", hostname))
+			result.SetError(idx, UpdateNoHost, fmt.Errorf("no zone found for '%s'", hostname))
+			continue
+		}
+
+		var zone = matches[0]
+
+		if false == user.AllowsHost(hostname, zone.Name) {
+			u.logger.Debug(fmt.Sprintf("user %s is not allowed to update hostname %s", user.Name, hostname))
+			result.SetError(idx, UpdateNoHost, fmt.Errorf("not allowed to update '%s'", hostname))
+			continue
+		}
 
-					u.logger.Debug(fmt.Sprintf("hostname %s matches zone %s (module %s)", hostname, zone, plugin.Module().Path))
+		result.SetZone(idx, zone)
 
-					if _, ok := updates[x]; !ok {
-						updates[x] = make(map[string][]libdns.Record)
-					}
+		for _, zone := range u.getZones(matches) {
 
-					if _, ok := updates[x][zone]; !ok {
-						updates[x][zone] = make([]libdns.Record, 0)
-					}
+			u.logger.Debug(fmt.Sprintf("hostname %s matches zone %s (module %s)", hostname, zone.Name, zone.Plugin.Module().Path))
 
-					updates[x][zone] = append(updates[x][zone], libdns.Address{
-						Name: libdns.RelativeName(hostname, zone),
-						TTL:  (time.Minute * 5).Round(time.Second),
-						IP:   ip,
-					})
+			if _, ok := updates[zone.Index]; !ok {
+				updates[zone.Index] = make(map[string]*zoneUpdate)
+			}
 
-					continue hostnames
+			if _, ok := updates[zone.Index][zone.Name]; !ok {
+				updates[zone.Index][zone.Name] = &zoneUpdate{
+					set:    make([]libdns.Record, 0),
+					delete: make([]libdns.Record, 0),
+					keep:   options.TTL == 0 && nil != zone.Plugin.TTLPolicy() && zone.Plugin.TTLPolicy().Keep,
 				}
 			}
 
-			u.logger.Debug(fmt.Sprintf("hostname %s is not supported by module %s (%s)", hostname, plugin.Module().Path, strings.Join(zones[x], ", ")))
-		}
+			if u.fanOut() != "" {
+				result.AddProvider(idx, zone)
+			}
 
-		result.Set(idx, "nohost")
+			result.SetAddrs(idx, u.makeHostRecords(updates[zone.Index][zone.Name], zone, hostname, addrs, options))
+		}
 	}
 
 	return updates
 }
 
-func (u *UpdateHandler) updateRecords(ctx context.Context, hosts []string, result *UpdateResult, items map[string][]libdns.Record, provider BaseProvider, ip *netip.Addr, lock sync.Locker) {
+// setFailedPlugins sets dnserr for a host that could be served by a plugin
+// that failed listing its zones, which in fan-out mode are reported as the
+// failed providers of the host.
+func (u *UpdateHandler) setFailedPlugins(idx int, hostname string, failed map[int]error, result *UpdateResult) {
+
+	var indexes = slices.Sorted(maps.Keys(failed))
+
+	if u.fanOut() == "" {
+
+		var errs = make([]error, len(indexes))
+
+		for i, c := 0, len(indexes); i < c; i++ {
+			errs[i] = fmt.Errorf("%s: error listing zones: %w", u.resolver.Plugins()[indexes[i]].Module().Path, failed[indexes[i]])
+		}
+
+		result.SetError(idx, UpdateDnsErr, fmt.Errorf("could not resolve the zone for '%s': %w", hostname, errors.Join(errs...)))
+		return
+	}
+
+	for _, index := range indexes {
+		result.AddFailedProvider(idx, u.resolver.Plugins()[index], index, failed[index])
+	}
+}
+
+// getTTL resolves the TTL for a host where the TTL from the request
+// takes precedence over the host, zone and plugin configuration and
+// the result is clamped to the range supported by the provider.
+func (u *UpdateHandler) getTTL(zone *ZoneEntry, policy *HostPolicy, options *UpdateOptions) time.Duration {
+
+	var ttl = zone.Plugin.TTLPolicy().Get(zone.Name, 5*time.Minute)
+
+	if policy.TTL > 0 {
+		ttl = time.Duration(policy.TTL)
+	}
+
+	if options.TTL > 0 {
+		ttl = options.TTL
+	}
+
+	return zone.Plugin.TTLPolicy().Clamp(ttl).Round(time.Second)
+}
+
+// makeHostRecords adds the records for a single host to the zone update
+// and returns the addresses that are published for the host.
+func (u *UpdateHandler) makeHostRecords(update *zoneUpdate, zone *ZoneEntry, hostname string, addrs UpdateAddrs, options *UpdateOptions) UpdateAddrs {
+
+	var name = zone.RelativeName(hostname)
+	var policy = u.getPolicy(hostname)
+	var ttl = u.getTTL(zone, policy, options)
+	var names = []string{name}
+	var wildcard = "*." + name
+
+	if name == "@" {
+		wildcard = "*"
+	}
+
+	switch options.Wildcard {
+	case "ON":
+		names = append(names, wildcard)
+	case "OFF":
+		update.delete = append(update.delete, libdns.RR{Name: wildcard, Type: "A"}, libdns.RR{Name: wildcard, Type: "AAAA"})
+	}
+
+	if options.Offline {
+
+		addrs = UpdateAddrs{}
+
+		for _, ip := range policy.Offline {
+			addrs.Set(ip)
+		}
+
+		u.logger.Debug(fmt.Sprintf("host %s is set offline, switching to '%s'", hostname, addrs))
+	}
+
+	for _, x := range names {
+		for idx, ip := range []netip.Addr{addrs.V4, addrs.V6} {
+
+			if ip.IsValid() {
+				update.set = append(update.set, libdns.Address{
+					Name: x,
+					TTL:  ttl,
+					IP:   ip,
+				})
+
+				continue
+			}
+
+			var rtype = "A"
+
+			if idx == 1 {
+				rtype = "AAAA"
+			}
+
+			if options.Offline || (rtype == "AAAA" && policy.RemoveStaleAAAA) {
+				u.logger.Debug(fmt.Sprintf("no %s address for %s, removing stale %s records", rtype, hostname, rtype))
+				update.delete = append(update.delete, libdns.RR{Name: x, Type: rtype})
+			}
+		}
+	}
+
+	if nil != options.MX {
+
+		if *options.MX == "" {
+			update.delete = append(update.delete, libdns.RR{Name: name, Type: "MX"})
+		} else {
+
+			var mx = libdns.MX{
+				Name:       name,
+				TTL:        ttl,
+				Preference: 10,
+				Target:     fqdn(*options.MX),
+			}
+
+			if options.BackMX {
+				update.set = append(update.set, libdns.MX{Name: name, TTL: mx.TTL, Preference: 10, Target: fqdn(hostname)})
+				mx.Preference = 50
+			}
+
+			update.set = append(update.set, mx)
+		}
+	}
+
+	return addrs
+}
+
+func (u *UpdateHandler) updateRecords(ctx context.Context, hosts []string, result *UpdateResult, items map[string]*zoneUpdate, index int, provider BaseProvider, lock sync.Locker) {
 
 	defer lock.Unlock()
 
-	for zone, records := range items {
-		sets, err := provider.SetRecords(ctx, zone, records)
+	for zone, update := range items {
+
+		current, err := provider.GetRecords(ctx, zone)
 
 		if err != nil {
-			u.logger.Error(fmt.Sprintf("failed updating records for zone %s: %s", zone, err.Error()))
-			u.setResponses(hosts, result, zone, records, "dnserr")
+			u.logger.Error(fmt.Sprintf("failed fetching records for zone %s: %s", zone, err.Error()))
+			u.setResponses(hosts, result, index, zone, update.set, UpdateDnsErr, err)
+			u.setResponses(hosts, result, index, zone, update.delete, UpdateDnsErr, err)
 			continue
 		}
 
-		if len(sets) > 0 {
-			u.setResponses(hosts, result, zone, sets, fmt.Sprintf("good %s", ip))
+		var records = NewRecordSet(zone, current...)
+
+		if update.keep {
+			for idx, record := range update.set {
+				if ttl, ok := records.TTL(zone, record); ok {
+					update.set[idx] = withTTL(record, ttl)
+				}
+			}
+		}
+
+		var changed, unchanged = records.Diff(zone, update.set)
+		var removes = make([]libdns.Record, 0)
+
+		for _, record := range update.delete {
+			if records.Has(zone, record) {
+				removes = append(removes, record)
+			} else {
+				unchanged = append(unchanged, record)
+			}
+		}
+
+		// mark all as unchanged and let the result of the
+		// write actions overwrite the hosts that did change
+		u.setResponses(hosts, result, index, zone, unchanged, UpdateNoChange, nil)
+
+		if len(changed) > 0 {
+			if _, err := provider.SetRecords(ctx, zone, changed); err != nil {
+				u.logger.Error(fmt.Sprintf("failed updating records for zone %s: %s", zone, err.Error()))
+				u.setResponses(hosts, result, index, zone, changed, UpdateDnsErr, err)
+				continue
+			}
+
+			u.setResponses(hosts, result, index, zone, changed, UpdateGood, nil)
+		}
+
+		if len(removes) > 0 {
+			if _, err := provider.DeleteRecords(ctx, zone, removes); err != nil {
+				u.logger.Error(fmt.Sprintf("failed removing stale records for zone %s: %s", zone, err.Error()))
+				u.setResponses(hosts, result, index, zone, removes, UpdateDnsErr, err)
+				continue
+			}
+
+			u.setResponses(hosts, result, index, zone, removes, UpdateGood, nil)
 		}
 	}
 }
 
-func (u *UpdateHandler) setResponses(hosts []string, result *UpdateResult, zone string, items []libdns.Record, value string) {
+// setResponses sets the return code for the hosts of the records, which in
+// fan-out mode is the code for the plugin with given index.
+func (u *UpdateHandler) setResponses(hosts []string, result *UpdateResult, index int, zone string, items []libdns.Record, code string, err error) {
 	for _, item := range items {
 		if x := u.getHostIdx(hosts, item.RR().Name, zone); x != -1 {
-			result.Set(x, value)
+			if u.fanOut() != "" {
+				result.SetProvider(x, index, code, err)
+			} else if err != nil {
+				result.SetError(x, code, err)
+			} else {
+				result.Set(x, code)
+			}
 		}
 	}
 }
 
 func (u *UpdateHandler) getHostIdx(hosts []string, name string, zone string) int {
 
-	var hostname = strings.TrimSuffix(libdns.AbsoluteName(name, zone), ".")
+	// wildcard records are reported on the host they belong to
+	if name = strings.TrimPrefix(name, "*."); name == "*" {
+		name = "@"
+	}
+
+	var hostname = strings.ToLower(strings.TrimSuffix(libdns.AbsoluteName(name, zone), "."))
 
 	for i, c := 0, len(hosts); i < c; i++ {
 		if hostname == hosts[i] {
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"runtime/debug"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+	"github.com/pbergman/logger"
+)
+
+// testProvider is an in memory provider that serves the records of its zones
+type testProvider struct {
+	module   string
+	priority int
+	zones    []string
+	// err is returned when listing the zones
+	err     error
+	records map[string][]libdns.Record
+	writes  int
+	lock    sync.Mutex
+}
+
+func newTestProvider(module string, zones ...string) *testProvider {
+	return &testProvider{module: module, zones: zones, records: make(map[string][]libdns.Record)}
+}
+
+func (p *testProvider) Module() *debug.Module {
+	return &debug.Module{Path: p.module}
+}
+
+func (p *testProvider) Priority() int {
+	return p.priority
+}
+
+func (p *testProvider) TTLPolicy() *TTLPolicy {
+	return nil
+}
+
+func (p *testProvider) ListZones(_ context.Context) ([]libdns.Zone, error) {
+
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	var zones = make([]libdns.Zone, len(p.zones))
+
+	for i, c := 0, len(p.zones); i < c; i++ {
+		zones[i] = libdns.Zone{Name: p.zones[i]}
+	}
+
+	return zones, nil
+}
+
+func (p *testProvider) GetRecords(_ context.Context, zone string) ([]libdns.Record, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return append([]libdns.Record(nil), p.records[zone]...), nil
+}
+
+func (p *testProvider) SetRecords(_ context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var set = NewRecordSet(zone, records...)
+	var list = make([]libdns.Record, 0)
+
+	for _, record := range p.records[zone] {
+		if false == set.Has(zone, record) {
+			list = append(list, record)
+		}
+	}
+
+	p.records[zone] = append(list, records...)
+	p.writes++
+
+	return records, nil
+}
+
+func (p *testProvider) AppendRecords(_ context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.records[zone] = append(p.records[zone], records...)
+	p.writes++
+
+	return records, nil
+}
+
+func (p *testProvider) DeleteRecords(_ context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var deleted = make([]libdns.Record, 0)
+	var list = make([]libdns.Record, 0)
+
+outer:
+	for _, record := range p.records[zone] {
+
+		var rr = record.RR()
+
+		for _, x := range records {
+			if x := x.RR(); rrKey(x.Name, x.Type, zone) == rrKey(rr.Name, rr.Type, zone) && (x.Data == "" || rrData(x) == rrData(rr)) {
+				deleted = append(deleted, record)
+				continue outer
+			}
+		}
+
+		list = append(list, record)
+	}
+
+	p.records[zone] = list
+	p.writes++
+
+	return deleted, nil
+}
+
+func testLogger() *logger.Logger {
+	return logger.NewLogger("test", logger.NewWriterHandler(io.Discard, logger.LogLevelDebug(), false))
+}
+
+func testUpdate(t *testing.T, handler Handler, query string) string {
+
+	var request = httptest.NewRequest("GET", "/nic/update?"+query, nil)
+	var response = httptest.NewRecorder()
+
+	request.Header.Set("User-Agent", "test")
+	request.RemoteAddr = "192.0.2.1:1234"
+
+	handler.Handle(response, request)
+
+	return response.Body.String()
+}
+
+func TestUpdateHandlerFailedPlugin(t *testing.T) {
+
+	var parent = newTestProvider("parent", "example.com")
+	var failed = newTestProvider("failed", "sub.example.com")
+
+	failed.err = errors.New("unavailable")
+
+	var plugins = []PluginProvider{parent, failed}
+
+	for _, mode := range []FanOutMode{"", FanOutAll, FanOutAny} {
+
+		var handler = NewUpdateHandler(NewZoneResolver(plugins), testLogger(), &ServerUpdateConfig{FanOut: mode}, nil)
+
+		// x.sub.example.com would otherwise be written to example.com of the parent
+		if out := testUpdate(t, handler, "hostname=www.example.com,x.sub.example.com&myip=192.0.2.1"); out != "dnserr\ndnserr" {
+			t.Errorf("fan-out '%s': unexpected result %q", mode, out)
+		}
+
+		if parent.writes > 0 {
+			t.Errorf("fan-out '%s': expected no writes when listing zones failed", mode)
+		}
+	}
+
+	failed.err = nil
+
+	var handler = NewUpdateHandler(NewZoneResolver(plugins), testLogger(), nil, nil)
+
+	if out := testUpdate(t, handler, "hostname=www.example.com,x.sub.example.com&myip=192.0.2.1"); out != "good 192.0.2.1\ngood 192.0.2.1" {
+		t.Errorf("unexpected result %q", out)
+	}
+
+	if len(parent.records["example.com"]) != 1 || len(failed.records["sub.example.com"]) != 1 {
+		t.Errorf("expected the hosts to be written to the most specific zone")
+	}
+}
+
+func TestUpdateHandlerInterval(t *testing.T) {
+
+	var provider = newTestProvider("test", "example.com")
+	var limiter = NewLimiter(&RateLimitConfig{UpdateInterval: Duration(time.Minute)}, testLogger())
+	var handler = NewUpdateHandler(NewZoneResolver([]PluginProvider{provider}), testLogger(), nil, limiter)
+
+	// a failed update can be retried right away
+	if out := testUpdate(t, handler, "hostname=www.example.org&myip=192.0.2.1"); out != "nohost" {
+		t.Errorf("unexpected result %q", out)
+	}
+
+	if out := testUpdate(t, handler, "hostname=www.example.org&myip=192.0.2.1"); out != "nohost" {
+		t.Errorf("unexpected result %q", out)
+	}
+
+	for _, x := range []struct {
+		query  string
+		result string
+	}{
+		{"hostname=www.example.com&myip=192.0.2.1", "good 192.0.2.1"},
+		{"hostname=www.example.com&myip=192.0.2.1", "abuse"},
+		{"hostname=mail.example.com&myip=192.0.2.1", "good 192.0.2.1"},
+		{"hostname=mail.example.com,www.example.com&myip=192.0.2.2", "abuse\nabuse"},
+	} {
+		if out := testUpdate(t, handler, x.query); out != x.result {
+			t.Errorf("%s: expected %q got %q", x.query, x.result, out)
+		}
+	}
+
+	provider.records["example.com"] = []libdns.Record{libdns.RR{Name: "nochg", Type: "A", TTL: 5 * time.Minute, Data: "192.0.2.1"}}
+
+	// unchanged updates start the interval too
+	for _, expected := range []string{"nochg 192.0.2.1", "abuse"} {
+		if out := testUpdate(t, handler, "hostname=nochg.example.com&myip=192.0.2.1"); out != expected {
+			t.Errorf("expected %q got %q", expected, out)
+		}
+	}
+
+	if provider.writes != 2 {
+		t.Errorf("expected 2 writes, got %d", provider.writes)
+	}
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"net/netip"
@@ -11,25 +13,91 @@ import (
 	"time"
 )
 
-// getIp first checks if an IP address is provided in the request query.
+// UpdateAddrs holds the addresses, one for every ip family, that
+// should be published for an update request. A family that was not
+// reported (or could not be detected) is left as the zero value.
+type UpdateAddrs struct {
+	V4 netip.Addr
+	V6 netip.Addr
+}
+
+// Set will store the given address in the slot for its family
+func (a *UpdateAddrs) Set(addr netip.Addr) {
+
+	if false == addr.IsValid() {
+		return
+	}
+
+	if addr = addr.Unmap(); addr.Is4() {
+		a.V4 = addr
+	} else {
+		a.V6 = addr
+	}
+}
+
+func ipFamily(addr netip.Addr) string {
+
+	if addr.Is4() {
+		return "ipv4"
+	}
+
+	return "ipv6"
+}
+
+func (a UpdateAddrs) IsValid() bool {
+	return a.V4.IsValid() || a.V6.IsValid()
+}
+
+func (a UpdateAddrs) String() string {
+	var list = make([]string, 0, 2)
+
+	for _, addr := range []netip.Addr{a.V4, a.V6} {
+		if addr.IsValid() {
+			list = append(list, addr.String())
+		}
+	}
+
+	return strings.Join(list, ",")
+}
+
+// getIp first checks if an IP address is provided in the request query,
+// myip can hold a single address or a comma-separated v4/v6 pair and
+// myipv6 (as send by some clients) an additional ipv6 address.
+//
 // If it’s omitted or cannot be parsed, it attempts to determine the
 // client’s IP address by processing the X-Forwarded-For header.
 // If no valid IP is found (or the header is missing), it will either
 // return the remote address from the connection or attempt to retrieve
-// the WAN address depending on config (NoLocalIp).
-func getIp(query url.Values, remoteAddr string, header http.Header, config *ServerUpdateConfig) (netip.Addr, error) {
+// the WAN addresses (for both families) depending on config (NoLocalIp).
+func getIp(query url.Values, remoteAddr string, header http.Header, config *ServerUpdateConfig) (UpdateAddrs, error) {
+
+	var addrs UpdateAddrs
 
 	// https://help.dyn.com/perform-update.html
-	if query.Has("myip") {
-		if value, err := netip.ParseAddr(query.Get("myip")); err == nil {
-			return value, nil
+	for _, name := range []string{"myip", "myipv6"} {
+		for _, addr := range getIpAddrFromList(query[name]) {
+
+			if addr = addr.Unmap(); false == addr.IsValid() {
+				continue
+			}
+
+			// an ambiguous request is rejected instead of silently picking one of the addresses
+			if (addr.Is4() && addrs.V4.IsValid() && addrs.V4 != addr) || (addr.Is6() && addrs.V6.IsValid() && addrs.V6 != addr) {
+				return addrs, NewUpdateError(UpdateServErr, fmt.Errorf("multiple %s addresses given in %s", ipFamily(addr), name))
+			}
+
+			addrs.Set(addr)
 		}
 	}
 
+	if addrs.IsValid() {
+		return addrs, nil
+	}
+
 	remote, err := netip.ParseAddrPort(remoteAddr)
 
 	if err != nil {
-		return netip.Addr{}, err
+		return addrs, err
 	}
 
 	var locals = sync.OnceValue(func() *IPPrefixList {
@@ -48,32 +116,114 @@ func getIp(query url.Values, remoteAddr string, header http.Header, config *Serv
 	if nil == config || nil == config.TrustedRemotes || false == config.TrustedRemotes.Contains(remote.Addr()) {
 
 		if nil != config && config.NoLocalIp && locals().Contains(remote.Addr()) {
-			return getRemoteIp()
+			return getRemoteIps()
 		}
 
-		return remote.Addr(), nil
+		addrs.Set(remote.Addr())
+
+		return addrs, nil
 	}
 
 	var list = getIpAddrFromList(header.Values("x-forwarded-for"))
 
 	for i := len(list) - 1; i >= 0; i-- {
 		if list[i].IsValid() && false == config.TrustedRemotes.Contains(list[i]) && (config.NoLocalIp && false == locals().Contains(list[i])) {
-			return list[i], nil
+			addrs.Set(list[i])
+			return addrs, nil
 		}
 	}
 
 	if config.NoLocalIp {
-		return getRemoteIp()
+		return getRemoteIps()
+	}
+
+	addrs.Set(remote.Addr())
+
+	return addrs, nil
+}
+
+// getClientAddr returns the address of the client, which is the remote
+// address of the connection or, when connected through a trusted proxy,
+// the first untrusted address of the X-Forwarded-For chain.
+func getClientAddr(remoteAddr string, header http.Header, config *ServerUpdateConfig) (netip.Addr, error) {
+
+	remote, err := netip.ParseAddrPort(remoteAddr)
+
+	if err != nil {
+		return netip.Addr{}, err
+	}
+
+	if nil == config || nil == config.TrustedRemotes || false == config.TrustedRemotes.Contains(remote.Addr()) {
+		return remote.Addr().Unmap(), nil
 	}
 
-	return remote.Addr(), nil
+	var list = getIpAddrFromList(header.Values("x-forwarded-for"))
+
+	for i := len(list) - 1; i >= 0; i-- {
+		if list[i].IsValid() && false == config.TrustedRemotes.Contains(list[i]) {
+			return list[i].Unmap(), nil
+		}
+	}
+
+	return remote.Addr().Unmap(), nil
+}
+
+// getRemoteIps will resolve the WAN address for both ip families and
+// only returns an error when none of them could be resolved.
+func getRemoteIps() (UpdateAddrs, error) {
+
+	var addrs UpdateAddrs
+	var errs = make([]error, 2)
+	var wg sync.WaitGroup
+
+	for idx, network := range []string{"ip4", "ip6"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			addr, err := getRemoteIp(network)
+
+			if err != nil {
+				errs[idx] = err
+				return
+			}
+
+			switch network {
+			case "ip4":
+				addrs.V4 = addr
+			case "ip6":
+				addrs.V6 = addr
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if false == addrs.IsValid() {
+		return addrs, errors.Join(errs...)
+	}
+
+	return addrs, nil
 }
 
 // getRemoteIp will do dns query to get remote ip similar
 // to the command
 //
 //	dig +short @resolver1.opendns.com myip.opendns.com
-func getRemoteIp() (netip.Addr, error) {
+//
+// for ipv6 the query is done over ipv6 against the ipv6
+// sandbox of opendns so we get the AAAA record in return
+//
+//	dig +short -6 AAAA @resolver1.ipv6-sandbox.opendns.com myip.opendns.com
+func getRemoteIp(network string) (netip.Addr, error) {
+
+	var server = "resolver1.opendns.com:53"
+	var family = "4"
+
+	if network == "ip6" {
+		server = "resolver1.ipv6-sandbox.opendns.com:53"
+		family = "6"
+	}
 
 	var resolver = &net.Resolver{
 		PreferGo: true,
@@ -83,11 +233,11 @@ func getRemoteIp() (netip.Addr, error) {
 				Timeout: time.Millisecond * time.Duration(10000),
 			}
 
-			return dialer.DialContext(ctx, network, "resolver1.opendns.com:53")
+			return dialer.DialContext(ctx, network+family, server)
 		},
 	}
 
-	ip, err := resolver.LookupHost(context.Background(), "myip.opendns.com")
+	ip, err := resolver.LookupNetIP(context.Background(), network, "myip.opendns.com")
 
 	if err != nil {
 		return netip.Addr{}, err
@@ -97,7 +247,7 @@ func getRemoteIp() (netip.Addr, error) {
 		return netip.Addr{}, nil
 	}
 
-	return netip.ParseAddr(ip[0])
+	return ip[0].Unmap(), nil
 }
 
 func getIpAddrFromList(list []string) []netip.Addr {
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"testing"
+)
+
+func TestGetIp(t *testing.T) {
+
+	var trusted = IPPrefixList{netip.MustParsePrefix("10.0.0.1/32")}
+
+	for name, x := range map[string]struct {
+		query  string
+		remote string
+		xff    string
+		config *ServerUpdateConfig
+		addrs  string
+	}{
+		"myip":                   {"myip=192.0.2.1", "198.51.100.1:80", "", nil, "192.0.2.1"},
+		"myip pair":              {"myip=192.0.2.1,2001:db8::1", "198.51.100.1:80", "", nil, "192.0.2.1,2001:db8::1"},
+		"myipv6":                 {"myip=192.0.2.1&myipv6=2001:db8::1", "198.51.100.1:80", "", nil, "192.0.2.1,2001:db8::1"},
+		"mapped myip":            {"myip=::ffff:192.0.2.1", "198.51.100.1:80", "", nil, "192.0.2.1"},
+		"same address twice":     {"myip=192.0.2.1,192.0.2.1", "198.51.100.1:80", "", nil, "192.0.2.1"},
+		"invalid myip":           {"myip=foo", "198.51.100.1:80", "", nil, "198.51.100.1"},
+		"remote":                 {"", "198.51.100.1:80", "203.0.113.1", nil, "198.51.100.1"},
+		"untrusted proxy":        {"", "198.51.100.1:80", "203.0.113.1", &ServerUpdateConfig{TrustedRemotes: &trusted}, "198.51.100.1"},
+		"trusted proxy":          {"", "10.0.0.1:80", "203.0.113.1", &ServerUpdateConfig{TrustedRemotes: &trusted}, "10.0.0.1"},
+		"trusted proxy no local": {"", "10.0.0.1:80", "203.0.113.1, 192.168.1.1", &ServerUpdateConfig{TrustedRemotes: &trusted, NoLocalIp: true}, "203.0.113.1"},
+	} {
+		query, _ := url.ParseQuery(x.query)
+
+		var header = make(http.Header)
+
+		if x.xff != "" {
+			header.Set("X-Forwarded-For", x.xff)
+		}
+
+		addrs, err := getIp(query, x.remote, header, x.config)
+
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+
+		if addrs.String() != x.addrs {
+			t.Errorf("%s: expected %s got %s", name, x.addrs, addrs)
+		}
+	}
+}
+
+func TestGetIpAmbiguous(t *testing.T) {
+
+	for _, query := range []string{"myip=192.0.2.1,192.0.2.2", "myip=2001:db8::1&myipv6=2001:db8::2"} {
+
+		values, _ := url.ParseQuery(query)
+
+		var ref *UpdateError
+
+		if _, err := getIp(values, "198.51.100.1:80", nil, nil); false == errors.As(err, &ref) || ref.Code != UpdateServErr {
+			t.Errorf("%s: expected a %s error, got %v", query, UpdateServErr, err)
+		}
+	}
+}
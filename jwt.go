@@ -0,0 +1,522 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pbergman/logger"
+)
+
+// JWTConfig holds the settings for authentication with (OIDC) JWT bearer
+// tokens which are verified against the keys of a JWKS file or url.
+type JWTConfig struct {
+	// JWKS is a file or http(s) url of the JSON Web Key Set
+	JWKS string `json:"jwks"`
+	// Issuer and Audience are required, as otherwise any token the
+	// identity provider signed (for any client) would be accepted.
+	Issuer   string `json:"issuer"`
+	Audience string `json:"audience"`
+	// UserClaim is the claim with the name of the user, defaults to sub
+	UserClaim string `json:"user_claim"`
+	// RoleClaim is an (optional) claim with the roles of the user,
+	// which are limited to the configured roles
+	RoleClaim string `json:"role_claim"`
+	// Roles for users that are not in the users config, which defaults
+	// to read. With a role claim these are the roles a token may claim.
+	Roles []Role `json:"roles"`
+	// Leeway is the allowed clock skew for the exp and nbf claims
+	Leeway Duration `json:"leeway"`
+}
+
+// Validate checks that the issuer and audience are configured
+func (c *JWTConfig) Validate() error {
+
+	if c.Issuer == "" || c.Audience == "" {
+		return errors.New("jwt: issuer and audience are required")
+	}
+
+	return nil
+}
+
+func NewJWTAuthenticator(config *JWTConfig, users *UserList, logger *logger.Logger) *JWTAuthenticator {
+
+	var authenticator = &JWTAuthenticator{
+		config: config,
+		keys:   &jwkSet{source: config.JWKS, logger: logger},
+	}
+
+	if nil != users {
+		authenticator.users = *users
+	}
+
+	return authenticator
+}
+
+// JWTAuthenticator authenticates requests with a JWT bearer token, the
+// user is looked up in the users config (so the same restrictions apply)
+// or otherwise created with the roles from the config, or the roles of
+// the role claim that are in the config.
+type JWTAuthenticator struct {
+	config *JWTConfig
+	users  UserList
+	keys   *jwkSet
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtAudience []string
+
+func (a *jwtAudience) UnmarshalJSON(data []byte) error {
+
+	var value string
+
+	if err := json.Unmarshal(data, &value); err == nil {
+		*a = jwtAudience{value}
+		return nil
+	}
+
+	return json.Unmarshal(data, (*[]string)(a))
+}
+
+func (j *JWTAuthenticator) Authenticate(request *http.Request) (*User, error) {
+
+	var token = getBearerToken(request)
+
+	if token == "" || strings.HasPrefix(token, TokenPrefix) || strings.Count(token, ".") != 2 {
+		return nil, nil
+	}
+
+	claims, err := j.verify(request.Context(), token)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var claim = j.config.UserClaim
+
+	if claim == "" {
+		claim = "sub"
+	}
+
+	name, ok := claims[claim].(string)
+
+	if false == ok || name == "" {
+		return nil, fmt.Errorf("token has no '%s' claim", claim)
+	}
+
+	if user, ok := j.users[name]; ok && nil != user {
+		return user, nil
+	}
+
+	var user = &User{Name: name, Roles: j.config.Roles}
+
+	if len(user.Roles) == 0 {
+		user.Roles = []Role{RoleRead}
+	}
+
+	// the claimed roles are limited to the configured roles, so the
+	// identity provider can't grant more than the config allows
+	if value, ok := claims[j.config.RoleClaim]; ok && j.config.RoleClaim != "" {
+
+		var roles = make([]Role, 0)
+
+		for _, role := range getClaimRoles(value) {
+			if slices.Contains(user.Roles, role) {
+				roles = append(roles, role)
+			}
+		}
+
+		if len(roles) == 0 {
+			return nil, fmt.Errorf("token of %s has no allowed roles", name)
+		}
+
+		user.Roles = roles
+	}
+
+	return user, nil
+}
+
+func (j *JWTAuthenticator) Challenge() string {
+	return `Bearer realm="DDNS Server"`
+}
+
+// getClaimRoles returns the known roles from a claim, which can be
+// a single (space separated) string or a list of strings.
+func getClaimRoles(value any) []Role {
+
+	var names []string
+	var roles []Role
+
+	switch x := value.(type) {
+	case string:
+		names = strings.Fields(x)
+	case []any:
+		for _, item := range x {
+			if name, ok := item.(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+
+	for _, name := range names {
+		if role := Role(name); role.IsValid() {
+			roles = append(roles, role)
+		}
+	}
+
+	return roles
+}
+
+// verify checks the signature of the token and validates the issuer,
+// audience and time claims and returns the claims of the token.
+func (j *JWTAuthenticator) verify(ctx context.Context, token string) (map[string]any, error) {
+
+	var parts = strings.Split(token, ".")
+	var header jwtHeader
+
+	if err := decodeJwtPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid token header: %w", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid token signature: %w", err)
+	}
+
+	key, err := j.keys.Get(ctx, header.Kid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifyJwtSignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]any
+	var registered struct {
+		Issuer    string      `json:"iss"`
+		Audience  jwtAudience `json:"aud"`
+		Expires   *float64    `json:"exp"`
+		NotBefore *float64    `json:"nbf"`
+	}
+
+	if err := decodeJwtPart(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid token claims: %w", err)
+	}
+
+	if err := decodeJwtPart(parts[1], &registered); err != nil {
+		return nil, fmt.Errorf("invalid token claims: %w", err)
+	}
+
+	var now = time.Now()
+	var leeway = time.Duration(j.config.Leeway)
+
+	if nil == registered.Expires || now.After(time.Unix(int64(*registered.Expires), 0).Add(leeway)) {
+		return nil, errors.New("token expired")
+	}
+
+	if nil != registered.NotBefore && now.Before(time.Unix(int64(*registered.NotBefore), 0).Add(-leeway)) {
+		return nil, errors.New("token not yet valid")
+	}
+
+	if registered.Issuer == "" || registered.Issuer != j.config.Issuer {
+		return nil, fmt.Errorf("invalid token issuer '%s'", registered.Issuer)
+	}
+
+	if j.config.Audience == "" || false == slices.Contains(registered.Audience, j.config.Audience) {
+		return nil, errors.New("invalid token audience")
+	}
+
+	return claims, nil
+}
+
+func decodeJwtPart(part string, v any) error {
+
+	data, err := base64.RawURLEncoding.DecodeString(part)
+
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// verifyJwtSignature checks the signature for the supported
+// algorithms, which are RS256, ES256 and EdDSA (Ed25519)
+func verifyJwtSignature(alg string, key crypto.PublicKey, data, signature []byte) error {
+
+	switch alg {
+	case "RS256":
+		if x, ok := key.(*rsa.PublicKey); ok {
+			var sum = sha256.Sum256(data)
+
+			if err := rsa.VerifyPKCS1v15(x, crypto.SHA256, sum[:], signature); err != nil {
+				return errors.New("invalid token signature")
+			}
+
+			return nil
+		}
+	case "ES256":
+		if x, ok := key.(*ecdsa.PublicKey); ok {
+			var sum = sha256.Sum256(data)
+
+			if len(signature) != 64 || false == ecdsa.Verify(x, sum[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])) {
+				return errors.New("invalid token signature")
+			}
+
+			return nil
+		}
+	case "EdDSA":
+		if x, ok := key.(ed25519.PublicKey); ok {
+			if false == ed25519.Verify(x, data, signature) {
+				return errors.New("invalid token signature")
+			}
+
+			return nil
+		}
+	default:
+		return fmt.Errorf("unsupported token algorithm '%s'", alg)
+	}
+
+	return fmt.Errorf("key does not match token algorithm '%s'", alg)
+}
+
+// jwkSet holds the keys of a JWKS file or url, which are (re)loaded when
+// a key is not found or the keys are older than an hour, with at most
+// one reload per minute.
+type jwkSet struct {
+	source string
+	logger *logger.Logger
+	lock   sync.Mutex
+	keys   map[string]crypto.PublicKey
+	loaded time.Time
+	tried  time.Time
+}
+
+// Get returns the key by id, the keys are (re)loaded without holding
+// the lock so other requests are not blocked by a slow jwks url.
+func (s *jwkSet) Get(ctx context.Context, kid string) (crypto.PublicKey, error) {
+
+	s.lock.Lock()
+
+	var key, ok = s.lookup(kid)
+	var reload = (false == ok || time.Since(s.loaded) > time.Hour) && time.Since(s.tried) > time.Minute
+
+	if reload {
+		s.tried = time.Now()
+	}
+
+	s.lock.Unlock()
+
+	if reload {
+
+		keys, err := s.load(ctx)
+
+		if err != nil {
+			s.logger.Error(fmt.Sprintf("failed to load jwks '%s': %s", s.source, err.Error()))
+		} else {
+			s.lock.Lock()
+			s.keys = keys
+			s.loaded = time.Now()
+			key, ok = s.lookup(kid)
+			s.lock.Unlock()
+		}
+	}
+
+	if false == ok {
+		return nil, fmt.Errorf("unknown token key '%s'", kid)
+	}
+
+	return key, nil
+}
+
+// lookup returns the key by id, or when the token has no key id
+// and the set only has one key, that key.
+func (s *jwkSet) lookup(kid string) (crypto.PublicKey, bool) {
+
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+
+	key, ok := s.keys[kid]
+
+	return key, ok
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// load reads the keys from the jwks file or url
+func (s *jwkSet) load(ctx context.Context) (map[string]crypto.PublicKey, error) {
+
+	var data []byte
+	var err error
+
+	if strings.HasPrefix(s.source, "https://") || strings.HasPrefix(s.source, "http://") {
+		data, err = fetchUrl(ctx, s.source)
+	} else {
+		data, err = os.ReadFile(s.source)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	var keys = make(map[string]crypto.PublicKey)
+
+	for _, item := range set.Keys {
+
+		if item.Use != "" && item.Use != "sig" {
+			continue
+		}
+
+		key, err := item.PublicKey()
+
+		if err != nil {
+			s.logger.Warning(fmt.Sprintf("ignoring key '%s' from jwks: %s", item.Kid, err.Error()))
+			continue
+		}
+
+		keys[item.Kid] = key
+	}
+
+	s.logger.Debug(fmt.Sprintf("loaded %d key(s) from '%s'", len(keys), s.source))
+
+	return keys, nil
+}
+
+func (k *jsonWebKey) PublicKey() (crypto.PublicKey, error) {
+
+	var decode = func(values ...string) ([][]byte, error) {
+		var ret = make([][]byte, len(values))
+
+		for i, value := range values {
+			x, err := base64.RawURLEncoding.DecodeString(value)
+
+			if err != nil {
+				return nil, err
+			}
+
+			ret[i] = x
+		}
+
+		return ret, nil
+	}
+
+	switch {
+	case k.Kty == "RSA":
+		values, err := decode(k.N, k.E)
+
+		if err != nil {
+			return nil, err
+		}
+
+		var n, e = new(big.Int).SetBytes(values[0]), new(big.Int).SetBytes(values[1])
+
+		if n.BitLen() < 2048 {
+			return nil, fmt.Errorf("RSA key of %d bits is too small, minimum is 2048", n.BitLen())
+		}
+
+		if false == e.IsInt64() || e.Int64() < 3 || e.Int64() > math.MaxInt32 || e.Bit(0) == 0 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case k.Kty == "EC" && k.Crv == "P-256":
+		values, err := decode(k.X, k.Y)
+
+		if err != nil {
+			return nil, err
+		}
+
+		var x, y = new(big.Int).SetBytes(values[0]), new(big.Int).SetBytes(values[1])
+
+		if false == elliptic.P256().IsOnCurve(x, y) {
+			return nil, errors.New("invalid P-256 point")
+		}
+
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	case k.Kty == "OKP" && k.Crv == "Ed25519":
+		values, err := decode(k.X)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(values[0]) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key size")
+		}
+
+		return ed25519.PublicKey(values[0]), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %s %s", k.Kty, k.Crv)
+	}
+}
+
+func fetchUrl(ctx context.Context, url string) ([]byte, error) {
+
+	var ctx2, cancel = context.WithTimeout(ctx, 10*time.Second)
+
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx2, http.MethodGet, url, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := http.DefaultClient.Do(request)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", response.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(response.Body, 1<<20))
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pbergman/logger"
+)
+
+// RateLimitConfig holds the settings for the failed authentication
+// lockouts and the minimum interval between updates of a host.
+type RateLimitConfig struct {
+	// MaxFailures is the number of failed authentications after which
+	// a client or user is locked out, a negative value disables lockouts.
+	MaxFailures int `json:"max_failures"`
+	// Lockout is the duration of the first lockout, which doubles for
+	// every failed authentication after that up to MaxLockout.
+	Lockout    Duration `json:"lockout"`
+	MaxLockout Duration `json:"max_lockout"`
+	// UpdateInterval is the minimum time between updates of a
+	// host, which can be overwritten per host with min_interval.
+	UpdateInterval Duration `json:"update_interval"`
+}
+
+// LimitEntry holds the counters for a single client, user or host
+type LimitEntry struct {
+	// Failures is the number of failed authentications
+	Failures int `json:"failures,omitempty"`
+	// Rejected is the number of requests rejected while limited
+	Rejected int       `json:"rejected"`
+	Last     time.Time `json:"last"`
+	Until    time.Time `json:"until"`
+	// previous holds the entry before the last allowed
+	// update, which is restored when that update failed
+	previous *LimitEntry
+}
+
+// Limiter keeps track of failed authentications (per ip and user) and
+// the updates per host, where all methods are safe to call on a nil
+// limiter which will then allow everything.
+type Limiter struct {
+	config   RateLimitConfig
+	logger   *logger.Logger
+	lock     sync.Mutex
+	failures map[string]*LimitEntry
+	updates  map[string]*LimitEntry
+	pruned   time.Time
+}
+
+func NewLimiter(config *RateLimitConfig, logger *logger.Logger) *Limiter {
+
+	var limiter = &Limiter{
+		config: RateLimitConfig{
+			MaxFailures: 5,
+			Lockout:     Duration(time.Minute),
+			MaxLockout:  Duration(time.Hour),
+		},
+		logger:   logger,
+		failures: make(map[string]*LimitEntry),
+		updates:  make(map[string]*LimitEntry),
+	}
+
+	if nil != config {
+
+		if config.MaxFailures != 0 {
+			limiter.config.MaxFailures = config.MaxFailures
+		}
+
+		if config.Lockout > 0 {
+			limiter.config.Lockout = config.Lockout
+		}
+
+		if config.MaxLockout > 0 {
+			limiter.config.MaxLockout = config.MaxLockout
+		}
+
+		limiter.config.UpdateInterval = config.UpdateInterval
+	}
+
+	return limiter
+}
+
+// Locked checks if one of the keys is locked out and returns
+// the remaining time of the longest lockout.
+func (l *Limiter) Locked(keys ...string) (time.Duration, bool) {
+
+	if nil == l {
+		return 0, false
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	var now = time.Now()
+	var wait time.Duration
+
+	for _, key := range keys {
+		if entry, ok := l.failures[key]; ok && now.Before(entry.Until) {
+			entry.Rejected++
+			wait = max(wait, entry.Until.Sub(now))
+		}
+	}
+
+	return wait, wait > 0
+}
+
+// Fail registers a failed authentication for the keys and locks them out
+// when the maximum failures is reached, where every following failure
+// doubles the lockout duration.
+func (l *Limiter) Fail(keys ...string) {
+
+	if nil == l || l.config.MaxFailures < 0 {
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	var now = time.Now()
+
+	l.prune(now)
+
+	for _, key := range keys {
+
+		var entry, ok = l.failures[key]
+
+		if false == ok {
+			entry = new(LimitEntry)
+			l.failures[key] = entry
+		}
+
+		entry.Failures++
+		entry.Last = now
+
+		if entry.Failures >= l.config.MaxFailures {
+
+			var lockout = time.Duration(l.config.Lockout)
+
+			for i := l.config.MaxFailures; i < entry.Failures && lockout < time.Duration(l.config.MaxLockout); i++ {
+				lockout *= 2
+			}
+
+			lockout = min(lockout, time.Duration(l.config.MaxLockout))
+			entry.Until = now.Add(lockout)
+
+			l.logger.Notice(fmt.Sprintf("%s locked out for %s after %d failed authentications", key, lockout, entry.Failures))
+		}
+	}
+}
+
+// Reset removes the failures for the keys after a successful authentication
+func (l *Limiter) Reset(keys ...string) {
+
+	if nil == l {
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for _, key := range keys {
+		delete(l.failures, key)
+	}
+}
+
+// AllowUpdate checks if the host may be updated, which is not allowed when
+// the last update was less than the given interval ago. When the interval
+// is zero, the default update interval from the config is used. An allowed
+// update starts the interval right away (so concurrent requests can not both
+// pass) and should be released when the update failed.
+func (l *Limiter) AllowUpdate(hostname string, interval time.Duration) (time.Duration, bool) {
+
+	if interval = l.updateInterval(interval); interval <= 0 {
+		return 0, true
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	var now = time.Now()
+
+	l.prune(now)
+
+	var entry, ok = l.updates[hostname]
+
+	if ok && now.Sub(entry.Last) < interval {
+		entry.Rejected++
+		entry.Until = entry.Last.Add(interval)
+
+		if entry.Rejected == 1 {
+			l.logger.Warning(fmt.Sprintf("host %s is updated too often (interval %s)", hostname, interval))
+		}
+
+		return entry.Until.Sub(now), false
+	}
+
+	var previous *LimitEntry
+
+	if ok {
+		previous = &LimitEntry{Last: entry.Last, Until: entry.Until}
+	}
+
+	l.updates[hostname] = &LimitEntry{Last: now, Until: now.Add(interval), previous: previous}
+
+	return 0, true
+}
+
+// Release undoes the last allowed update of the host, so an update that
+// failed (dnserr or 911) can be retried without waiting for the interval.
+func (l *Limiter) Release(hostname string) {
+
+	if nil == l {
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if entry, ok := l.updates[hostname]; ok {
+		if nil == entry.previous {
+			delete(l.updates, hostname)
+		} else {
+			l.updates[hostname] = entry.previous
+		}
+	}
+}
+
+func (l *Limiter) updateInterval(interval time.Duration) time.Duration {
+
+	if nil == l {
+		return 0
+	}
+
+	if interval <= 0 {
+		interval = time.Duration(l.config.UpdateInterval)
+	}
+
+	return interval
+}
+
+// prune removes the entries that are no longer limited, the failures
+// are kept until max lockout has passed since the last failure.
+func (l *Limiter) prune(now time.Time) {
+
+	if now.Sub(l.pruned) < time.Minute {
+		return
+	}
+
+	l.pruned = now
+
+	for key, entry := range l.failures {
+		if now.After(entry.Until) && now.Sub(entry.Last) > time.Duration(l.config.MaxLockout) {
+			delete(l.failures, key)
+		}
+	}
+
+	for key, entry := range l.updates {
+		if now.After(entry.Until) {
+			delete(l.updates, key)
+		}
+	}
+}
+
+// LimitStatus is a snapshot of the limiter counters
+type LimitStatus struct {
+	Failures map[string]LimitEntry `json:"failures"`
+	Updates  map[string]LimitEntry `json:"updates"`
+}
+
+func (l *Limiter) Status() *LimitStatus {
+
+	var status = &LimitStatus{
+		Failures: make(map[string]LimitEntry),
+		Updates:  make(map[string]LimitEntry),
+	}
+
+	if nil == l {
+		return status
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.prune(time.Now())
+
+	for key, entry := range l.failures {
+		status.Failures[key] = *entry
+	}
+
+	for key, entry := range l.updates {
+		status.Updates[key] = *entry
+	}
+
+	return status
+}
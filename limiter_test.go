@@ -0,0 +1,145 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLimiterAllowUpdate(t *testing.T) {
+
+	var limiter = NewLimiter(&RateLimitConfig{UpdateInterval: Duration(time.Minute)}, testLogger())
+
+	if _, ok := limiter.AllowUpdate("www.example.com", 0); false == ok {
+		t.Fatal("expected the first update to be allowed")
+	}
+
+	wait, ok := limiter.AllowUpdate("www.example.com", 0)
+
+	if ok || wait <= 0 || wait > time.Minute {
+		t.Errorf("expected the update to be limited, got %v (%s)", ok, wait)
+	}
+
+	if _, ok := limiter.AllowUpdate("other.example.com", 0); false == ok {
+		t.Error("expected an update of another host to be allowed")
+	}
+
+	// the interval of the host takes precedence over the default
+	if _, ok := limiter.AllowUpdate("www.example.com", time.Nanosecond); false == ok {
+		t.Error("expected the update to be allowed with a shorter interval")
+	}
+
+	if status := limiter.Status(); status.Updates["www.example.com"].Rejected != 0 || len(status.Updates) != 2 {
+		t.Errorf("unexpected status %+v", status.Updates)
+	}
+}
+
+func TestLimiterRelease(t *testing.T) {
+
+	var limiter = NewLimiter(nil, testLogger())
+
+	if _, ok := limiter.AllowUpdate("www.example.com", time.Minute); false == ok {
+		t.Fatal("expected the first update to be allowed")
+	}
+
+	limiter.Release("www.example.com")
+
+	if _, ok := limiter.AllowUpdate("www.example.com", time.Minute); false == ok {
+		t.Fatal("expected a released update to be allowed")
+	}
+
+	var last = limiter.Status().Updates["www.example.com"].Last
+
+	// a released update restores the previous one
+	if _, ok := limiter.AllowUpdate("www.example.com", time.Nanosecond); false == ok {
+		t.Fatal("expected the update to be allowed with a shorter interval")
+	}
+
+	limiter.Release("www.example.com")
+
+	if status := limiter.Status(); false == status.Updates["www.example.com"].Last.Equal(last) {
+		t.Errorf("expected the previous update to be restored, got %+v", status.Updates)
+	}
+
+	if _, ok := limiter.AllowUpdate("www.example.com", time.Minute); ok {
+		t.Error("expected the update to be limited by the previous update")
+	}
+}
+
+func TestLimiterAllowUpdateConcurrent(t *testing.T) {
+
+	var limiter = NewLimiter(nil, testLogger())
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := limiter.AllowUpdate("www.example.com", time.Minute); ok {
+				allowed.Add(1)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if x := allowed.Load(); x != 1 {
+		t.Errorf("expected 1 allowed update, got %d", x)
+	}
+}
+
+func TestLimiterNoInterval(t *testing.T) {
+
+	for _, limiter := range []*Limiter{nil, NewLimiter(nil, testLogger())} {
+		for i := 0; i < 3; i++ {
+			if _, ok := limiter.AllowUpdate("www.example.com", 0); false == ok {
+				t.Error("expected updates to be allowed without an interval")
+			}
+		}
+
+		limiter.Release("www.example.com")
+	}
+}
+
+func TestLimiterLockout(t *testing.T) {
+
+	var limiter = NewLimiter(&RateLimitConfig{MaxFailures: 2, Lockout: Duration(time.Minute), MaxLockout: Duration(3 * time.Minute)}, testLogger())
+
+	limiter.Fail("127.0.0.1", "foo")
+
+	if _, ok := limiter.Locked("127.0.0.1", "foo"); ok {
+		t.Fatal("expected no lockout after one failure")
+	}
+
+	limiter.Fail("127.0.0.1", "foo")
+
+	if wait, ok := limiter.Locked("127.0.0.1"); false == ok || wait > time.Minute {
+		t.Errorf("expected a lockout of 1m, got %v (%s)", ok, wait)
+	}
+
+	limiter.Fail("127.0.0.1")
+	limiter.Fail("127.0.0.1")
+
+	// doubles with every failure up to the max lockout
+	if wait, ok := limiter.Locked("127.0.0.1"); false == ok || wait <= 2*time.Minute || wait > 3*time.Minute {
+		t.Errorf("expected a lockout of 3m, got %v (%s)", ok, wait)
+	}
+
+	limiter.Reset("foo")
+
+	if _, ok := limiter.Locked("foo"); ok {
+		t.Error("expected no lockout after a reset")
+	}
+
+	var disabled = NewLimiter(&RateLimitConfig{MaxFailures: -1}, testLogger())
+
+	for i := 0; i < 10; i++ {
+		disabled.Fail("127.0.0.1")
+	}
+
+	if _, ok := disabled.Locked("127.0.0.1"); ok {
+		t.Error("expected no lockout when disabled")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"runtime/debug"
+	"time"
 )
 
 func main() {
@@ -24,10 +25,32 @@ func main() {
 		}
 	case "run":
 		run(logger, level)
-	case "records", "zones", "lookup", "inspect":
+	case "passwd":
+		if err := passwd(os.Stdin, os.Stdout, os.Stderr, flag.Args()[1:]...); err != nil {
+			os.Stderr.WriteString(err.Error() + "\n")
+			os.Exit(1)
+		}
+	case "sign":
+		if err := sign(inputOption("config", ""), os.Stdout, flag.Args()[1:]...); err != nil {
+			os.Stderr.WriteString(err.Error() + "\n")
+			os.Exit(1)
+		}
+	case "token":
+		if err := token(os.Stdout, inputOption("expires", time.Duration(0)), flag.Args()[1:]...); err != nil {
+			os.Stderr.WriteString(err.Error() + "\n")
+			os.Exit(1)
+		}
+	case "records", "zones", "lookup", "inspect", "get", "set", "append", "delete", "export", "import", "plan", "apply", "mirror":
+
+		format, err := ParseFormat(inputOption("format", "table"))
+
+		if err != nil {
+			os.Stderr.WriteString(err.Error() + "\n")
+			os.Exit(1)
+		}
 
 		var locker = NewSemaphore(5)
-		var _, providers, err = bootstrap(
+		config, providers, err := bootstrap(
 			logger,
 			inputOption("config", ""),
 			level,
@@ -38,13 +61,40 @@ func main() {
 			os.Exit(1)
 		}
 
+		var resolver = NewZoneResolver(providers)
+
 		switch c {
 		case "records":
-			WriteRecords(context.Background(), providers, locker, os.Stdout, os.Stderr, flag.Args()[1:]...)
+			WriteRecords(context.Background(), resolver, locker, format, os.Stdout, os.Stderr, flag.Args()[1:]...)
 		case "zones":
-			WriteZones(context.Background(), providers, locker, os.Stdout, os.Stderr, flag.Args()[1:]...)
+			WriteZones(context.Background(), resolver, locker, format, os.Stdout, os.Stderr, flag.Args()[1:]...)
+		case "get", "set", "append", "delete":
+			if err := record(context.Background(), resolver, c, inputOption("module", ""), format, os.Stdout, flag.Args()[1:]...); err != nil {
+				os.Stderr.WriteString(err.Error() + "\n")
+				os.Exit(1)
+			}
+		case "export":
+			if err := exportZone(context.Background(), resolver, inputOption("module", ""), os.Stdout, flag.Args()[1:]...); err != nil {
+				os.Stderr.WriteString(err.Error() + "\n")
+				os.Exit(1)
+			}
+		case "import":
+			if err := importZone(context.Background(), resolver, inputOption("module", ""), inputOption("dry-run", false), os.Stdin, os.Stdout, flag.Args()[1:]...); err != nil {
+				os.Stderr.WriteString(err.Error() + "\n")
+				os.Exit(1)
+			}
+		case "plan", "apply":
+			if err := syncZones(context.Background(), config, resolver, c == "apply", os.Stdout, flag.Args()[1:]...); err != nil {
+				os.Stderr.WriteString(err.Error() + "\n")
+				os.Exit(1)
+			}
+		case "mirror":
+			if err := mirror(config, resolver, logger); err != nil {
+				os.Stderr.WriteString(err.Error() + "\n")
+				os.Exit(1)
+			}
 		case "inspect":
-			WritePlugin(context.Background(), providers, locker, os.Stdout, os.Stderr, flag.Args()[1:]...)
+			WritePlugin(context.Background(), providers, locker, format, os.Stdout, os.Stderr, flag.Args()[1:]...)
 		default:
 
 			if flag.NArg() < 2 || flag.NArg() > 3 {
@@ -62,7 +112,7 @@ func main() {
 				hostname = flag.Arg(2)
 			}
 
-			WriteShort(context.Background(), providers, locker, os.Stdout, os.Stderr, rtype, hostname)
+			WriteShort(context.Background(), resolver, locker, format, os.Stdout, os.Stderr, rtype, hostname)
 		}
 	default:
 		flag.Usage()
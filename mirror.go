@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libdns/libdns"
+	"github.com/pbergman/logger"
+)
+
+// MirrorConfig defines a zone that is mirrored from the source
+// provider to the target provider.
+type MirrorConfig struct {
+	Source MirrorZone `json:"source"`
+	Target MirrorZone `json:"target"`
+	// Interval between polls of the source, defaults to 5 minutes
+	Interval Duration `json:"interval"`
+	// Exclude holds glob patterns of hostnames (in the source zone) that are not mirrored
+	Exclude []string `json:"exclude"`
+}
+
+type MirrorZone struct {
+	// Module of the plugin, which is required when the zone is served by multiple plugins
+	Module string `json:"module"`
+	Zone   string `json:"zone"`
+}
+
+func (m MirrorZone) String() string {
+
+	if m.Module == "" {
+		return fqdn(m.Zone)
+	}
+
+	return m.Module + "/" + fqdn(m.Zone)
+}
+
+// Mirror copies the records of the source zone to the target zone and
+// keeps the target in sync by polling the source. Changes of the target
+// that are not made in the source are reported as drift and reverted.
+type Mirror struct {
+	config   *MirrorConfig
+	resolver *ZoneResolver
+	logger   *logger.Logger
+	excludes SyncExcludes
+	source   *ZoneEntry
+	target   *ZoneEntry
+	// last holds the source records of the last successful sync
+	last []libdns.Record
+	// ttls holds the TTL per RRset that was written to the target and the
+	// TTL the target returned for it, as providers can round or clamp TTLs
+	ttls map[string]*mirrorTTL
+}
+
+type mirrorTTL struct {
+	written time.Duration
+	stored  time.Duration
+}
+
+func NewMirror(config *MirrorConfig, resolver *ZoneResolver, logger *logger.Logger) *Mirror {
+	return &Mirror{
+		config:   config,
+		resolver: resolver,
+		logger:   logger.WithName("mirror"),
+		excludes: config.Exclude,
+		ttls:     make(map[string]*mirrorTTL),
+	}
+}
+
+// RunMirrors runs the mirrors until the context is done
+func RunMirrors(ctx context.Context, configs []*MirrorConfig, resolver *ZoneResolver, logger *logger.Logger) {
+
+	var wg sync.WaitGroup
+
+	for _, config := range configs {
+
+		wg.Add(1)
+
+		go func(mirror *Mirror) {
+			defer wg.Done()
+			mirror.Run(ctx)
+		}(NewMirror(config, resolver, logger))
+	}
+
+	wg.Wait()
+}
+
+// Run copies the records to the target and then polls the source
+// for changes until the context is done.
+func (m *Mirror) Run(ctx context.Context) {
+
+	var interval = time.Duration(m.config.Interval)
+
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+
+	m.logger.Debug(fmt.Sprintf("mirroring %s to %s every %s", m.config.Source, m.config.Target, interval))
+
+	for {
+
+		if err := m.Sync(ctx); err != nil && false == errors.Is(err, context.Canceled) {
+			m.logger.Error(fmt.Sprintf("failed to mirror %s to %s: %s", m.config.Source, m.config.Target, err.Error()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Sync applies the changes needed to get the target in sync with the source
+func (m *Mirror) Sync(ctx context.Context) error {
+
+	if err := m.resolve(ctx); err != nil {
+		return err
+	}
+
+	records, err := m.source.Plugin.GetRecords(ctx, m.source.Name)
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", m.config.Source, err)
+	}
+
+	current, err := m.target.Plugin.GetRecords(ctx, m.target.Name)
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", m.config.Target, err)
+	}
+
+	// the names of the records are relative, so the excludes (and apex
+	// records) of the target can be matched with the source zone name.
+	var desired = m.excludes.Filter(m.source.Name, records)
+	var policy = m.target.Plugin.TTLPolicy()
+
+	for idx, record := range desired {
+		if ttl := record.RR().TTL; policy.Clamp(ttl) != ttl {
+			desired[idx] = withTTL(record, policy.Clamp(ttl))
+		}
+	}
+
+	current = m.excludes.Filter(m.source.Name, current)
+
+	// protects the target against a source that (temporarily) returns no records
+	if len(desired) == 0 && len(current) > 0 {
+		return fmt.Errorf("%s returned no records, not removing all records from %s", m.config.Source, m.config.Target)
+	}
+
+	var changes = m.filterTTLs(DiffRecords(m.target.Name, current, desired, true))
+
+	if len(changes) == 0 {
+		m.last = desired
+		return nil
+	}
+
+	// changes of RRsets that did not change in the source since the last
+	// sync were made in the target, which are reported as drift.
+	if nil != m.last {
+
+		var updated = make(map[string]bool)
+
+		for _, change := range DiffRecords(m.target.Name, m.last, desired, true) {
+			updated[change.Key] = true
+		}
+
+		for _, change := range changes {
+			if false == updated[change.Key] {
+				m.logger.Warning(fmt.Sprintf("drift in %s: %s %s (%s)", m.config.Target, change.Key, change.Kind, driftReason(change)))
+			}
+		}
+	}
+
+	if err := ApplyChanges(ctx, m.target.Plugin, m.target.Name, changes); err != nil {
+		return err
+	}
+
+	for _, change := range changes {
+		if change.Kind == ChangeDelete {
+			delete(m.ttls, change.Key)
+		} else {
+			m.ttls[change.Key] = &mirrorTTL{written: change.Desired[0].TTL}
+		}
+	}
+
+	m.last = desired
+	m.logger.Notice(fmt.Sprintf("mirrored %d change(s) from %s to %s", len(changes), m.config.Source, m.config.Target))
+
+	return nil
+}
+
+// filterTTLs removes the updates that only differ in TTL, when the target
+// stores another TTL than was written (so the provider rounds or clamps the
+// TTL) and the TTL of the source did not change since it was written.
+func (m *Mirror) filterTTLs(changes []*RecordChange) []*RecordChange {
+
+	var filtered = make([]*RecordChange, 0, len(changes))
+
+	for _, change := range changes {
+
+		if change.Kind == ChangeUpdate && equalRRsetData(change.Current, change.Desired) {
+
+			var ttl, ok = m.ttls[change.Key]
+
+			if ok && ttl.written == change.Desired[0].TTL && (ttl.stored == 0 || ttl.stored == change.Current[0].TTL) {
+				ttl.stored = change.Current[0].TTL
+				continue
+			}
+		}
+
+		filtered = append(filtered, change)
+	}
+
+	return filtered
+}
+
+// equalRRsetData checks if the records have the same data, ignoring the TTL
+func equalRRsetData(a, b []libdns.RR) bool {
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	var x = make([]string, len(a))
+	var y = make([]string, len(b))
+
+	for i, c := 0, len(a); i < c; i++ {
+		x[i], y[i] = rrData(a[i]), rrData(b[i])
+	}
+
+	slices.Sort(x)
+	slices.Sort(y)
+
+	return slices.Equal(x, y)
+}
+
+// resolve looks up the source and target zone, which are kept once found
+func (m *Mirror) resolve(ctx context.Context) error {
+
+	if nil != m.source && nil != m.target {
+		return nil
+	}
+
+	source, err := findZone(ctx, m.resolver, m.config.Source.Zone, m.config.Source.Module)
+
+	if err != nil {
+		return fmt.Errorf("source %w", err)
+	}
+
+	target, err := findZone(ctx, m.resolver, m.config.Target.Zone, m.config.Target.Module)
+
+	if err != nil {
+		return fmt.Errorf("target %w", err)
+	}
+
+	if source.Plugin == target.Plugin && strings.EqualFold(fqdn(source.Name), fqdn(target.Name)) {
+		return fmt.Errorf("source and target of %s resolve to the same zone", m.config.Source)
+	}
+
+	m.source, m.target = source, target
+
+	return nil
+}
+
+func driftReason(change *RecordChange) string {
+	switch change.Kind {
+	case ChangeCreate:
+		return "missing in target"
+	case ChangeDelete:
+		return "not in source"
+	default:
+		return "differs from source"
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// roundingProvider stores the TTLs rounded up to whole minutes
+type roundingProvider struct {
+	*testProvider
+}
+
+func (p *roundingProvider) round(records []libdns.Record) []libdns.Record {
+
+	var rounded = make([]libdns.Record, len(records))
+
+	for idx, record := range records {
+		rounded[idx] = withTTL(record, (record.RR().TTL + time.Minute - 1).Truncate(time.Minute))
+	}
+
+	return rounded
+}
+
+func (p *roundingProvider) SetRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	return p.testProvider.SetRecords(ctx, zone, p.round(records))
+}
+
+func (p *roundingProvider) AppendRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	return p.testProvider.AppendRecords(ctx, zone, p.round(records))
+}
+
+func TestMirrorRoundedTTL(t *testing.T) {
+
+	var source = newTestProvider("source", "example.com")
+	var target = &roundingProvider{newTestProvider("target", "example.com")}
+	var mirror = NewMirror(&MirrorConfig{Source: MirrorZone{Module: "source", Zone: "example.com"}, Target: MirrorZone{Module: "target", Zone: "example.com"}}, NewZoneResolver([]PluginProvider{source, target}), testLogger())
+
+	source.records["example.com"] = []libdns.Record{libdns.RR{Name: "www", TTL: 90 * time.Second, Type: "A", Data: "192.0.2.1"}}
+
+	var sync = func(writes int) {
+
+		t.Helper()
+
+		if err := mirror.Sync(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if target.writes != writes {
+			t.Errorf("expected %d writes got %d", writes, target.writes)
+		}
+	}
+
+	sync(1)
+
+	// the target stores 2m for the 90s of the source
+	sync(1)
+	sync(1)
+
+	// a changed TTL in the target is drift
+	target.records["example.com"] = []libdns.Record{libdns.RR{Name: "www", TTL: 5 * time.Minute, Type: "A", Data: "192.0.2.1"}}
+
+	sync(2)
+	sync(2)
+
+	// a changed TTL in the source is written
+	source.records["example.com"] = []libdns.Record{libdns.RR{Name: "www", TTL: 150 * time.Second, Type: "A", Data: "192.0.2.1"}}
+
+	sync(3)
+	sync(3)
+
+	if ttl := target.records["example.com"][0].RR().TTL; ttl != 3*time.Minute {
+		t.Errorf("expected a TTL of 3m got %s", ttl)
+	}
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+const (
+	cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+var (
+	// dummyPasswordHash is verified for unknown users, which is a hash of
+	// the default algorithm (pbkdf2) so it takes as long as a known user.
+	dummyPasswordHash = "$pbkdf2-sha256$600000$/NpEt5D/AzxVvAC1h58gjQ$xn68NeYcdQDNcPuon0mLudMGdT258fKLARsZj.J2UE8"
+	// cryptEncoding is the base64 variant used by the crypt(3) formats
+	cryptEncoding = base64.NewEncoding(cryptAlphabet).WithPadding(base64.NoPadding)
+	// ab64Encoding is the "adapted base64" encoding as used by passlib for pbkdf2 hashes
+	ab64Encoding = base64.NewEncoding("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789./").WithPadding(base64.NoPadding)
+)
+
+// PasswordAlgorithms are the algorithms supported by HashPassword
+var PasswordAlgorithms = []string{"pbkdf2", "sha512", "sha256", "apr1", "sha"}
+
+// VerifyPassword checks the password against a stored password, which can
+// be hashed with one of the supported formats:
+//
+//	{SHA}<base64>                                 Apache SHA1
+//	$apr1$<salt>$<hash>                           Apache MD5
+//	$5$[rounds=<n>$]<salt>$<hash>                 SHA-256 crypt
+//	$6$[rounds=<n>$]<salt>$<hash>                 SHA-512 crypt
+//	$pbkdf2-<digest>$<rounds>$<salt>$<hash>       PBKDF2 (passlib format)
+//
+// When none of the formats match, the stored password is considered
+// plaintext. All comparisons are done in constant time.
+func VerifyPassword(stored, password string) bool {
+
+	var computed string
+
+	switch {
+	case strings.HasPrefix(stored, "{SHA}"):
+		computed = shaPassword(password)
+	case strings.HasPrefix(stored, "$apr1$"), strings.HasPrefix(stored, "$1$"):
+		var parts = strings.SplitN(stored, "$", 4)
+
+		if len(parts) != 4 {
+			return false
+		}
+
+		computed = md5Crypt([]byte(password), []byte(parts[2]), "$"+parts[1]+"$")
+	case strings.HasPrefix(stored, "$5$"), strings.HasPrefix(stored, "$6$"):
+		salt, rounds, ok := parseCryptSalt(stored)
+
+		if false == ok {
+			return false
+		}
+
+		computed = shaCrypt(stored[:3], []byte(password), salt, rounds)
+	case strings.HasPrefix(stored, "$pbkdf2"):
+		var parts = strings.Split(stored, "$")
+
+		if len(parts) != 5 {
+			return false
+		}
+
+		rounds, err := strconv.Atoi(parts[2])
+
+		if err != nil || rounds < 1 {
+			return false
+		}
+
+		salt, err := ab64Encoding.DecodeString(parts[3])
+
+		if err != nil {
+			return false
+		}
+
+		if computed, err = pbkdf2Password(parts[1], password, salt, rounds); err != nil {
+			return false
+		}
+	default:
+		computed = password
+	}
+
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(computed)) == 1
+}
+
+// HashPassword hashes the password with one of the PasswordAlgorithms
+func HashPassword(algorithm, password string) (string, error) {
+
+	var salt = make([]byte, 16)
+
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	switch algorithm {
+	case "pbkdf2":
+		return pbkdf2Password("pbkdf2-sha256", password, salt, 600000)
+	case "sha512":
+		return shaCrypt("$6$", []byte(password), []byte(cryptEncoding.EncodeToString(salt)[:16]), 0), nil
+	case "sha256":
+		return shaCrypt("$5$", []byte(password), []byte(cryptEncoding.EncodeToString(salt)[:16]), 0), nil
+	case "apr1":
+		return md5Crypt([]byte(password), []byte(cryptEncoding.EncodeToString(salt)[:8]), "$apr1$"), nil
+	case "sha":
+		return shaPassword(password), nil
+	default:
+		return "", fmt.Errorf("unsupported algorithm '%s' (supported: %s)", algorithm, strings.Join(PasswordAlgorithms, ", "))
+	}
+}
+
+func shaPassword(password string) string {
+	var sum = sha1.Sum([]byte(password))
+	return "{SHA}" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func pbkdf2Password(prefix, password string, salt []byte, rounds int) (string, error) {
+
+	var digest func() hash.Hash
+
+	switch prefix {
+	case "pbkdf2":
+		digest = sha1.New
+	case "pbkdf2-sha256":
+		digest = sha256.New
+	case "pbkdf2-sha512":
+		digest = sha512.New
+	default:
+		return "", fmt.Errorf("unsupported pbkdf2 digest '%s'", prefix)
+	}
+
+	key, err := pbkdf2.Key(digest, password, salt, rounds, digest().Size())
+
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("$%s$%d$%s$%s", prefix, rounds, ab64Encoding.EncodeToString(salt), ab64Encoding.EncodeToString(key)), nil
+}
+
+// parseCryptSalt returns the salt and (optional) rounds from a SHA-crypt hash
+func parseCryptSalt(stored string) ([]byte, int, bool) {
+
+	var parts = strings.Split(stored[3:], "$")
+	var rounds = 0
+
+	if len(parts) == 3 && strings.HasPrefix(parts[0], "rounds=") {
+
+		x, err := strconv.Atoi(parts[0][7:])
+
+		if err != nil {
+			return nil, 0, false
+		}
+
+		rounds, parts = x, parts[1:]
+	}
+
+	if len(parts) != 2 {
+		return nil, 0, false
+	}
+
+	return []byte(parts[0]), rounds, true
+}
+
+// shaCrypt implements the SHA-256 ($5$) and SHA-512 ($6$) crypt algorithm
+//
+// see https://www.akkadia.org/drepper/SHA-crypt.txt
+func shaCrypt(magic string, password, salt []byte, rounds int) string {
+
+	var digest = sha512.New
+	var prefix = magic
+
+	if magic == "$5$" {
+		digest = sha256.New
+	}
+
+	if len(salt) > 16 {
+		salt = salt[:16]
+	}
+
+	if rounds > 0 {
+		rounds = min(max(rounds, 1000), 999999999)
+		prefix += fmt.Sprintf("rounds=%d$", rounds)
+	} else {
+		rounds = 5000
+	}
+
+	var sum = func(parts ...[]byte) []byte {
+		var h = digest()
+
+		for _, part := range parts {
+			h.Write(part)
+		}
+
+		return h.Sum(nil)
+	}
+
+	var b = sum(password, salt, password)
+	var a = digest()
+
+	a.Write(password)
+	a.Write(salt)
+
+	for i := len(password); i > 0; i -= len(b) {
+		a.Write(b[:min(i, len(b))])
+	}
+
+	for i := len(password); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			a.Write(b)
+		} else {
+			a.Write(password)
+		}
+	}
+
+	var c = a.Sum(nil)
+	var p = repeatTo(sum(bytes.Repeat(password, len(password))), len(password))
+	var s = repeatTo(sum(bytes.Repeat(salt, 16+int(c[0]))), len(salt))
+
+	for i := 0; i < rounds; i++ {
+		var h = digest()
+
+		if i&1 != 0 {
+			h.Write(p)
+		} else {
+			h.Write(c)
+		}
+
+		if i%3 != 0 {
+			h.Write(s)
+		}
+
+		if i%7 != 0 {
+			h.Write(p)
+		}
+
+		if i&1 != 0 {
+			h.Write(c)
+		} else {
+			h.Write(p)
+		}
+
+		c = h.Sum(nil)
+	}
+
+	var buf strings.Builder
+
+	buf.WriteString(prefix)
+	buf.Write(salt)
+	buf.WriteByte('$')
+
+	if magic == "$5$" {
+		for _, x := range [][3]int{{0, 10, 20}, {21, 1, 11}, {12, 22, 2}, {3, 13, 23}, {24, 4, 14}, {15, 25, 5}, {6, 16, 26}, {27, 7, 17}, {18, 28, 8}, {9, 19, 29}} {
+			writeCrypt24(&buf, c[x[0]], c[x[1]], c[x[2]], 4)
+		}
+
+		writeCrypt24(&buf, 0, c[31], c[30], 3)
+	} else {
+		for i := 0; i < 21; i++ {
+			// the bytes rotate per group: (0, 21, 42), (22, 43, 1), (44, 2, 23)...
+			var x = [3]int{i, i + 21, i + 42}
+
+			writeCrypt24(&buf, c[x[i%3]], c[x[(i+1)%3]], c[x[(i+2)%3]], 4)
+		}
+
+		writeCrypt24(&buf, 0, 0, c[63], 2)
+	}
+
+	return buf.String()
+}
+
+// writeCrypt24 writes n crypt base64 characters for 3 bytes
+func writeCrypt24(buf *strings.Builder, b2, b1, b0 byte, n int) {
+
+	var w = uint(b2)<<16 | uint(b1)<<8 | uint(b0)
+
+	for i := 0; i < n; i++ {
+		buf.WriteByte(cryptAlphabet[w&0x3f])
+		w >>= 6
+	}
+}
+
+// md5Crypt implements the (Apache) MD5 crypt algorithm
+func md5Crypt(password, salt []byte, magic string) string {
+
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+
+	var final = md5.Sum(append(append(append([]byte{}, password...), salt...), password...))
+	var ctx = md5.New()
+
+	ctx.Write(password)
+	ctx.Write([]byte(magic))
+	ctx.Write(salt)
+
+	for i := len(password); i > 0; i -= 16 {
+		ctx.Write(final[:min(i, 16)])
+	}
+
+	for i := len(password); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			ctx.Write([]byte{0})
+		} else {
+			ctx.Write(password[:1])
+		}
+	}
+
+	var sum = ctx.Sum(nil)
+
+	for i := 0; i < 1000; i++ {
+		var h = md5.New()
+
+		if i&1 != 0 {
+			h.Write(password)
+		} else {
+			h.Write(sum)
+		}
+
+		if i%3 != 0 {
+			h.Write(salt)
+		}
+
+		if i%7 != 0 {
+			h.Write(password)
+		}
+
+		if i&1 != 0 {
+			h.Write(sum)
+		} else {
+			h.Write(password)
+		}
+
+		sum = h.Sum(nil)
+	}
+
+	var buf strings.Builder
+
+	buf.WriteString(magic)
+	buf.Write(salt)
+	buf.WriteByte('$')
+
+	for _, x := range [][3]int{{0, 6, 12}, {1, 7, 13}, {2, 8, 14}, {3, 9, 15}, {4, 10, 5}} {
+		writeCrypt24(&buf, sum[x[0]], sum[x[1]], sum[x[2]], 4)
+	}
+
+	writeCrypt24(&buf, 0, 0, sum[11], 2)
+
+	return buf.String()
+}
+
+// repeatTo repeats the input until it has given size
+func repeatTo(input []byte, size int) []byte {
+
+	var out = make([]byte, 0, size)
+
+	for len(out) < size {
+		out = append(out, input[:min(len(input), size-len(out))]...)
+	}
+
+	return out
+}
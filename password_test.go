@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// the crypt hashes are generated with openssl passwd, the rounds vectors
+// are from https://www.akkadia.org/drepper/SHA-crypt.txt and the pbkdf2
+// hashes with python hashlib.pbkdf2_hmac (in the passlib format)
+var passwordVectors = []struct {
+	password string
+	hash     string
+}{
+	{"Hello world!", "{SHA}00hq6RNueFa8QiEjhep5cJRHWAI="},
+	{"Hello world!", "$apr1$rTpm8bpL$7uJdxcYCGwzOXLbZYAxx1."},
+	{"", "$apr1$x$tMwYqBfQwi3FYAr0aJc8M/"},
+	{"Hello world!", "$1$saltsalt$le8lFSqqnPaRFOlmAZpvH1"},
+	{"Hello world!", "$5$saltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5"},
+	{"Hello world!", "$5$rounds=10000$saltstringsaltst$3xv.VbSHBb41AL9AvLeujZkZRBAwqFMz2.opqey6IcA"},
+	{"Hello world!", "$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1"},
+	{"This is just a test", "$6$rounds=5000$toolongsaltstrin$lQ8jolhgVRVhY4b5pZKaysCLi0QBxGoNeKQzQ3glMhwllF7oGDZxUhx1yxdYcz/e1JSbq3y6JMxxl8audkUEm0"},
+	{"the minimum number is still observed", "$6$rounds=1000$roundstoolow$kUMsbe306n21p9R.FRkW3IGn.S9NPN0x50YhH1xhLsPuWGsUSklZt58jaTfF4ZEQpyUNGc0dqbpBYYBaHHrsX."},
+	{"password", "$pbkdf2$1000$MDEyMzQ1Njc4OWFiY2RlZg$DYW.LTZG5wxyiF/qvsh40/./hXk"},
+	{"password", "$pbkdf2-sha256$1000$MDEyMzQ1Njc4OWFiY2RlZg$hRRjgXWkW8ResfIvBP99J/T4vkgEmMRV/0tJTOjR59I"},
+	{"password", "$pbkdf2-sha512$1000$MDEyMzQ1Njc4OWFiY2RlZg$38DzhdBT7fPaUGBlsh42VTuuKSFAIYGZJ7l6feCDLIl.K3hdPFgxxu7xuUi4gIuH6cEIoODn18xH9Ig2ryNgUw"},
+	{"plain", "plain"},
+}
+
+func TestVerifyPassword(t *testing.T) {
+
+	for _, x := range passwordVectors {
+
+		if false == VerifyPassword(x.hash, x.password) {
+			t.Errorf("expected %q to match %s", x.password, x.hash)
+		}
+
+		if VerifyPassword(x.hash, x.password+"x") {
+			t.Errorf("expected %q not to match %s", x.password+"x", x.hash)
+		}
+	}
+}
+
+func TestVerifyPasswordInvalid(t *testing.T) {
+
+	for _, hash := range []string{
+		"$apr1$",
+		"$5$",
+		"$6$rounds=abc$salt$hash",
+		"$pbkdf2-sha256$0$MDEyMzQ1Njc4OWFiY2RlZg$hash",
+		"$pbkdf2-sha256$1000$!!!$hash",
+		"$pbkdf2-md5$1000$MDEyMzQ1Njc4OWFiY2RlZg$hash",
+		"$pbkdf2-sha256$1000",
+	} {
+		if VerifyPassword(hash, "") {
+			t.Errorf("expected invalid hash %s not to match", hash)
+		}
+	}
+}
+
+func TestShaCryptRounds(t *testing.T) {
+
+	// the rounds are written as used, so clamped to the minimum of 1000
+	var hash = shaCrypt("$6$", []byte("the minimum number is still observed"), []byte("roundstoolow"), 10)
+
+	if hash != passwordVectors[8].hash {
+		t.Errorf("expected %s got %s", passwordVectors[8].hash, hash)
+	}
+}
+
+func TestDummyPasswordHash(t *testing.T) {
+
+	if false == strings.HasPrefix(dummyPasswordHash, "$pbkdf2-sha256$600000$") || VerifyPassword(dummyPasswordHash, "") {
+		t.Errorf("expected the dummy hash to be a pbkdf2 hash of the default algorithm")
+	}
+
+	if nil != NewUserStore(&UserList{"foo": &User{Name: "foo", Password: "bar"}}, nil).Verify("baz", "bar") {
+		t.Error("expected an unknown user not to verify")
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+
+	var prefixes = map[string]string{
+		"pbkdf2": "$pbkdf2-sha256$600000$",
+		"sha512": "$6$",
+		"sha256": "$5$",
+		"apr1":   "$apr1$",
+		"sha":    "{SHA}",
+	}
+
+	for _, algorithm := range PasswordAlgorithms {
+
+		hash, err := HashPassword(algorithm, "secret")
+
+		if err != nil {
+			t.Fatalf("%s: %s", algorithm, err)
+		}
+
+		if false == strings.HasPrefix(hash, prefixes[algorithm]) {
+			t.Errorf("%s: unexpected hash %s", algorithm, hash)
+		}
+
+		if false == VerifyPassword(hash, "secret") || VerifyPassword(hash, "Secret") {
+			t.Errorf("%s: hash %s does not verify", algorithm, hash)
+		}
+	}
+
+	if _, err := HashPassword("md4", "secret"); err == nil {
+		t.Error("expected an error for an unsupported algorithm")
+	}
+}
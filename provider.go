@@ -10,6 +10,8 @@ import (
 type PluginProvider interface {
 	ZoneAwareProvider
 	Module() *debug.Module
+	Priority() int
+	TTLPolicy() *TTLPolicy
 }
 type ZoneAwareProvider interface {
 	BaseProvider
@@ -47,9 +49,21 @@ func (z *StaticZoneProvider) ListZones(ctx context.Context) ([]libdns.Zone, erro
 
 type Provider struct {
 	ZoneAwareProvider
-	module *debug.Module
+	module   *debug.Module
+	priority int
+	ttl      *TTLPolicy
 }
 
 func (p *Provider) Module() *debug.Module {
 	return p.module
 }
+
+// Priority is used to order providers that serve the same
+// zone, where the provider with the highest value wins.
+func (p *Provider) Priority() int {
+	return p.priority
+}
+
+func (p *Provider) TTLPolicy() *TTLPolicy {
+	return p.ttl
+}
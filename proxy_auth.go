@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ProxyAuthConfig holds the settings for authentication by a reverse
+// proxy (like oauth2-proxy or Authelia) that passes the user in a header.
+type ProxyAuthConfig struct {
+	// Header with the name of the user, defaults to X-Remote-User
+	Header string `json:"header"`
+	// Roles for users that are not in the users config, defaults to read
+	Roles []Role `json:"roles"`
+}
+
+func NewProxyAuthenticator(config *ProxyAuthConfig, users *UserList, remotes *IPPrefixList) *ProxyAuthenticator {
+
+	var authenticator = &ProxyAuthenticator{
+		header:  config.Header,
+		roles:   config.Roles,
+		remotes: remotes,
+	}
+
+	if authenticator.header == "" {
+		authenticator.header = "X-Remote-User"
+	}
+
+	if len(authenticator.roles) == 0 {
+		authenticator.roles = []Role{RoleRead}
+	}
+
+	if nil != users {
+		authenticator.users = *users
+	}
+
+	return authenticator
+}
+
+// ProxyAuthenticator trusts the user header of requests that come
+// directly from one of the trusted remotes.
+type ProxyAuthenticator struct {
+	header  string
+	roles   []Role
+	users   UserList
+	remotes *IPPrefixList
+}
+
+func (p *ProxyAuthenticator) Authenticate(request *http.Request) (*User, error) {
+
+	var name = strings.TrimSpace(request.Header.Get(p.header))
+
+	if name == "" {
+		return nil, nil
+	}
+
+	remote, err := netip.ParseAddrPort(request.RemoteAddr)
+
+	if err != nil || nil == p.remotes || false == p.remotes.Contains(remote.Addr().Unmap()) {
+		return nil, errors.New("user header from untrusted remote")
+	}
+
+	if user, ok := p.users[name]; ok && nil != user {
+		return user, nil
+	}
+
+	return &User{Name: name, Roles: p.roles}, nil
+}
+
+func (p *ProxyAuthenticator) Challenge() string {
+	return ""
+}
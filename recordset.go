@@ -0,0 +1,143 @@
+package main
+
+import (
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// RecordSet groups records by their RRset (name and type) so the
+// records of a zone can be compared with the records we want to write.
+type RecordSet map[string][]libdns.RR
+
+func NewRecordSet(zone string, records ...libdns.Record) RecordSet {
+
+	var set = make(RecordSet)
+
+	for _, record := range records {
+		set.Add(zone, record)
+	}
+
+	return set
+}
+
+func (r RecordSet) Add(zone string, record libdns.Record) {
+	var rr = record.RR()
+	var key = rrKey(rr.Name, rr.Type, zone)
+
+	r[key] = append(r[key], rr)
+}
+
+// Has returns true when the zone has records for the RRset of given record
+func (r RecordSet) Has(zone string, record libdns.Record) bool {
+	var rr = record.RR()
+	return len(r[rrKey(rr.Name, rr.Type, zone)]) > 0
+}
+
+// TTL returns the TTL of the existing RRset of given record
+func (r RecordSet) TTL(zone string, record libdns.Record) (time.Duration, bool) {
+	var rr = record.RR()
+
+	if list := r[rrKey(rr.Name, rr.Type, zone)]; len(list) > 0 {
+		return list[0].TTL, true
+	}
+
+	return 0, false
+}
+
+// Equal returns true when the data and TTL of the RRset for given key matches
+// the data of the given records, the order of the records is ignored.
+func (r RecordSet) Equal(key string, records []libdns.RR) bool {
+
+	var current = r[key]
+
+	if len(current) != len(records) {
+		return false
+	}
+
+	var a = make([]string, len(current))
+	var b = make([]string, len(records))
+
+	for i, c := 0, len(current); i < c; i++ {
+		a[i] = rrData(current[i]) + " " + rrTTL(current[i])
+		b[i] = rrData(records[i]) + " " + rrTTL(records[i])
+	}
+
+	slices.Sort(a)
+	slices.Sort(b)
+
+	return slices.Equal(a, b)
+}
+
+// Diff splits the given records in the ones that would change the
+// zone and the ones that already match the records in the zone.
+func (r RecordSet) Diff(zone string, records []libdns.Record) (changed []libdns.Record, unchanged []libdns.Record) {
+
+	var wanted = NewRecordSet(zone, records...)
+
+	for _, record := range records {
+		var rr = record.RR()
+
+		if key := rrKey(rr.Name, rr.Type, zone); r.Equal(key, wanted[key]) {
+			unchanged = append(unchanged, record)
+		} else {
+			changed = append(changed, record)
+		}
+	}
+
+	return changed, unchanged
+}
+
+// fqdn returns the name as fully qualified domain name (with trailing dot)
+func fqdn(name string) string {
+
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+
+	return name + "."
+}
+
+// rrKey returns the identifier for the RRset of a record
+func rrKey(name, rtype, zone string) string {
+	return strings.ToLower(strings.TrimSuffix(libdns.AbsoluteName(name, zone), ".")) + " " + strings.ToUpper(rtype)
+}
+
+// rrTTL returns the TTL in seconds as zone files do
+func rrTTL(rr libdns.RR) string {
+	return strconv.Itoa(int(rr.TTL / time.Second))
+}
+
+// withTTL returns the record with given TTL
+func withTTL(record libdns.Record, ttl time.Duration) libdns.Record {
+	var rr = record.RR()
+
+	rr.TTL = ttl
+
+	if x, err := rr.Parse(); err == nil {
+		return x
+	}
+
+	return rr
+}
+
+// rrData returns normalised record data so records returned by a
+// provider can be compared with the records we want to write, where
+// the target names (the last field) are compared without trailing dot.
+func rrData(rr libdns.RR) string {
+
+	switch strings.ToUpper(rr.Type) {
+	case "A", "AAAA":
+		if ip, err := netip.ParseAddr(strings.TrimSpace(rr.Data)); err == nil {
+			return ip.Unmap().String()
+		}
+	case "MX", "SRV", "CNAME", "NS", "PTR", "DNAME":
+		return strings.TrimSuffix(strings.ToLower(strings.Join(strings.Fields(rr.Data), " ")), ".")
+	}
+
+	return strings.TrimSpace(rr.Data)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+func TestRecordSetDiff(t *testing.T) {
+
+	var zone = NewRecordSet("example.com.",
+		libdns.RR{Name: "www", TTL: time.Minute, Type: "A", Data: "192.0.2.1"},
+		libdns.RR{Name: "www", TTL: time.Minute, Type: "AAAA", Data: "2001:db8::1"},
+		libdns.RR{Name: "mail", TTL: time.Minute, Type: "MX", Data: "10 mx.example.com."},
+		libdns.RR{Name: "multi", TTL: time.Minute, Type: "A", Data: "192.0.2.1"},
+		libdns.RR{Name: "multi", TTL: time.Minute, Type: "A", Data: "192.0.2.2"},
+	)
+
+	for name, x := range map[string]struct {
+		records   []libdns.Record
+		changed   int
+		unchanged int
+	}{
+		"equal":              {[]libdns.Record{libdns.RR{Name: "www", TTL: time.Minute, Type: "A", Data: "192.0.2.1"}}, 0, 1},
+		"case and fqdn name": {[]libdns.Record{libdns.RR{Name: "WWW.example.com.", TTL: time.Minute, Type: "a", Data: "192.0.2.1"}}, 0, 1},
+		"mapped address":     {[]libdns.Record{libdns.RR{Name: "www", TTL: time.Minute, Type: "A", Data: "::ffff:192.0.2.1"}}, 0, 1},
+		"expanded address":   {[]libdns.Record{libdns.RR{Name: "www", TTL: time.Minute, Type: "AAAA", Data: "2001:0db8::0001"}}, 0, 1},
+		"target without dot": {[]libdns.Record{libdns.RR{Name: "mail", TTL: time.Minute, Type: "MX", Data: "10 MX.example.com"}}, 0, 1},
+		"other data":         {[]libdns.Record{libdns.RR{Name: "www", TTL: time.Minute, Type: "A", Data: "192.0.2.2"}}, 1, 0},
+		"other ttl":          {[]libdns.Record{libdns.RR{Name: "www", TTL: time.Hour, Type: "A", Data: "192.0.2.1"}}, 1, 0},
+		"new rrset":          {[]libdns.Record{libdns.RR{Name: "new", TTL: time.Minute, Type: "A", Data: "192.0.2.1"}}, 1, 0},
+		"other type":         {[]libdns.Record{libdns.RR{Name: "www", TTL: time.Minute, Type: "TXT", Data: "192.0.2.1"}}, 1, 0},
+		"rrset in any order": {[]libdns.Record{libdns.RR{Name: "multi", TTL: time.Minute, Type: "A", Data: "192.0.2.2"}, libdns.RR{Name: "multi", TTL: time.Minute, Type: "A", Data: "192.0.2.1"}}, 0, 2},
+		"partial rrset":      {[]libdns.Record{libdns.RR{Name: "multi", TTL: time.Minute, Type: "A", Data: "192.0.2.1"}}, 1, 0},
+		"mixed":              {[]libdns.Record{libdns.RR{Name: "www", TTL: time.Minute, Type: "A", Data: "192.0.2.1"}, libdns.RR{Name: "www", TTL: time.Minute, Type: "AAAA", Data: "2001:db8::2"}}, 1, 1},
+		"none":               {nil, 0, 0},
+	} {
+		changed, unchanged := zone.Diff("example.com.", x.records)
+
+		if len(changed) != x.changed || len(unchanged) != x.unchanged {
+			t.Errorf("%s: expected %d changed and %d unchanged, got %d and %d", name, x.changed, x.unchanged, len(changed), len(unchanged))
+		}
+	}
+}
@@ -2,24 +2,17 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net"
 	"net/http"
 	"net/netip"
+	"path"
+	"strings"
 
 	"github.com/pbergman/logger"
 )
 
-type UserList map[string]string
-
-func (u UserList) Authenticate(user, pass string) bool {
-
-	if v, ok := u[user]; !ok || v != pass {
-		return false
-	}
-
-	return true
-}
-
 type IPPrefixList []netip.Prefix
 
 func (t *IPPrefixList) Contains(ip netip.Addr) bool {
@@ -32,18 +25,131 @@ func (t *IPPrefixList) Contains(ip netip.Addr) bool {
 }
 
 type ServerConfig struct {
-	Users  *UserList `json:"users"`
-	Listen string    `json:"listen"`
+	Users     *UserList        `json:"users"`
+	UsersFile string           `json:"users_file"`
+	Tokens    TokenList        `json:"tokens"`
+	Listen    string           `json:"listen"`
+	RateLimit *RateLimitConfig `json:"rate_limit"`
+	TLS       *TLSConfig       `json:"tls"`
+	JWT       *JWTConfig       `json:"jwt"`
+	ProxyAuth *ProxyAuthConfig `json:"proxy_auth"`
+	// ChallengeZone is the zone for acme-dns subdomains
+	ChallengeZone string `json:"challenge_zone"`
 	ServerUpdateConfig
 }
 
 type ServerUpdateConfig struct {
 	TrustedRemotes *IPPrefixList `json:"trusted_remotes"`
 	NoLocalIp      bool          `json:"no_local_ip"`
+	Hosts          HostPolicies  `json:"hosts"`
+	// QueryTTL allows clients to set the TTL with the ttl parameter,
+	// which is limited to the users and tokens with query_ttl
+	QueryTTL bool `json:"query_ttl"`
+	// SignatureWindow is the maximum age of signed update requests
+	SignatureWindow Duration `json:"signature_window"`
+	// FanOut writes updates to every plugin that serves the zone of a
+	// host instead of only the preferred plugin (see FanOutMode)
+	FanOut FanOutMode `json:"fan_out"`
+}
+
+// FanOutMode defines how the result of a host is determined when the
+// update is written to multiple plugins, where an empty mode disables
+// the fan-out and only the preferred plugin is updated.
+type FanOutMode string
+
+const (
+	// FanOutAll requires the updates of all plugins to succeed
+	FanOutAll FanOutMode = "all"
+	// FanOutAny requires the update of at least one plugin to succeed
+	FanOutAny FanOutMode = "any"
+)
+
+func (f *FanOutMode) UnmarshalJSON(data []byte) error {
+
+	var value string
+
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	switch mode := FanOutMode(strings.ToLower(value)); mode {
+	case "", FanOutAll, FanOutAny:
+		*f = mode
+	default:
+		return fmt.Errorf("invalid fan_out '%s', expected all or any", value)
+	}
+
+	return nil
 }
 
-func NewServer(ctx context.Context, config *Config, logger *logger.Logger, plugins []PluginProvider) *http.Server {
-	return &http.Server{
+// HostPolicy holds the update settings for a single hostname
+// (or a group of hostnames when defined with a glob pattern)
+type HostPolicy struct {
+	// RemoveStaleAAAA will remove the AAAA records of a host
+	// when a client stops reporting an ipv6 address.
+	RemoveStaleAAAA bool `json:"remove_stale_aaaa"`
+	// Offline holds the addresses the host is switched to when a client
+	// sends offline=YES, when empty the A and AAAA records are removed.
+	Offline []netip.Addr `json:"offline"`
+	// TTL of the records for this host, which takes precedence
+	// over the TTL defined for the zone or plugin.
+	TTL Duration `json:"ttl"`
+	// MinInterval is the minimum time between updates of the
+	// host, updates within this interval are answered with abuse.
+	MinInterval Duration `json:"min_interval"`
+	// Secret is the shared secret for signed update requests
+	Secret string `json:"secret"`
+}
+
+type HostPolicies map[string]*HostPolicy
+
+// HasSecrets checks if a secret is defined for one of the hosts
+func (h HostPolicies) HasSecrets() bool {
+
+	for _, policy := range h {
+		if nil != policy && policy.Secret != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Get returns the policy for given hostname, it will first check for
+// an exact match and then for the longest matching glob pattern. When
+// nothing matches, it returns an empty policy.
+func (h HostPolicies) Get(hostname string) *HostPolicy {
+
+	hostname = strings.ToLower(strings.TrimSuffix(hostname, "."))
+
+	if v, ok := h[hostname]; ok && v != nil {
+		return v
+	}
+
+	var match string
+
+	for pattern, policy := range h {
+		if ok, _ := path.Match(strings.ToLower(pattern), hostname); ok && nil != policy && len(pattern) > len(match) {
+			match = pattern
+		}
+	}
+
+	if match != "" {
+		return h[match]
+	}
+
+	return &HostPolicy{}
+}
+
+func NewServer(ctx context.Context, config *Config, logger *logger.Logger, plugins []PluginProvider) (*http.Server, error) {
+
+	if nil != config.Server.JWT && "" != config.Server.JWT.JWKS {
+		if err := config.Server.JWT.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	var server = &http.Server{
 		Addr: config.Server.Listen,
 		Handler: NewServerHandler(
 			config.Server,
@@ -54,4 +160,17 @@ func NewServer(ctx context.Context, config *Config, logger *logger.Logger, plugi
 			return ctx
 		},
 	}
+
+	if nil != config.Server.TLS {
+
+		tlsConfig, err := NewTLSConfig(ctx, config.Server.TLS, logger, NewZoneResolver(plugins))
+
+		if err != nil {
+			return nil, err
+		}
+
+		server.TLSConfig = tlsConfig
+	}
+
+	return server, nil
 }
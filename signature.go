@@ -0,0 +1,149 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SignUpdate adds a timestamp, nonce and signature to the update query
+// of a single hostname, which is signed with the secret of the host.
+func SignUpdate(query url.Values, secret string, now time.Time) error {
+
+	var nonce = make([]byte, 12)
+
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	query.Del("sig")
+	query.Set("ts", strconv.FormatInt(now.Unix(), 10))
+	query.Set("nonce", hex.EncodeToString(nonce))
+	query.Set("sig", signQuery(query, secret))
+
+	return nil
+}
+
+// signQuery returns the hex encoded HMAC-SHA256 of all query parameters
+// (except sig) encoded as sorted query string, so the hostname, ip,
+// timestamp, nonce and all other parameters are covered.
+func signQuery(query url.Values, secret string) string {
+
+	var values = make(url.Values, len(query))
+
+	for key, value := range query {
+		if key != "sig" {
+			values[key] = value
+		}
+	}
+
+	var mac = hmac.New(sha256.New, []byte(secret))
+
+	mac.Write([]byte(values.Encode()))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func NewSignatureAuthenticator(config *ServerUpdateConfig) *SignatureAuthenticator {
+
+	var window = 5 * time.Minute
+
+	if config.SignatureWindow > 0 {
+		window = time.Duration(config.SignatureWindow)
+	}
+
+	return &SignatureAuthenticator{
+		hosts:  config.Hosts,
+		window: window,
+		nonces: make(map[string]time.Time),
+	}
+}
+
+// SignatureAuthenticator authenticates update requests that are signed
+// with the secret of the host, which makes it possible to update hosts
+// over plain http without sending credentials that can be reused.
+type SignatureAuthenticator struct {
+	hosts  HostPolicies
+	window time.Duration
+	lock   sync.Mutex
+	nonces map[string]time.Time
+}
+
+func (s *SignatureAuthenticator) Authenticate(request *http.Request) (*User, error) {
+
+	var query = request.URL.Query()
+
+	if request.URL.Path != "/nic/update" || false == query.Has("sig") {
+		return nil, nil
+	}
+
+	var hostname = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(query.Get("hostname")), "."))
+
+	if hostname == "" || strings.Contains(hostname, ",") {
+		return nil, errors.New("signed updates require a single hostname")
+	}
+
+	var policy = s.hosts.Get(hostname)
+
+	if policy.Secret == "" {
+		return nil, fmt.Errorf("no secret configured for '%s'", hostname)
+	}
+
+	if false == hmac.Equal([]byte(strings.ToLower(query.Get("sig"))), []byte(signQuery(query, policy.Secret))) {
+		return nil, errors.New("invalid signature")
+	}
+
+	ts, err := strconv.ParseInt(query.Get("ts"), 10, 64)
+
+	if err != nil {
+		return nil, errors.New("invalid timestamp")
+	}
+
+	var now = time.Now()
+
+	if diff := now.Sub(time.Unix(ts, 0)); diff > s.window || diff < -s.window {
+		return nil, errors.New("signature expired")
+	}
+
+	if nonce := query.Get("nonce"); len(nonce) < 8 || false == s.useNonce(hostname+":"+nonce, now) {
+		return nil, errors.New("invalid or reused nonce")
+	}
+
+	return &User{Name: "signed:" + hostname, Hosts: []string{hostname}, Roles: []Role{RoleUpdate}}, nil
+}
+
+// useNonce registers the nonce and returns false when it was already used
+// within the replay window. Nonces are kept twice the window because the
+// timestamp may be in the future as much as in the past.
+func (s *SignatureAuthenticator) useNonce(nonce string, now time.Time) bool {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for key, expires := range s.nonces {
+		if now.After(expires) {
+			delete(s.nonces, key)
+		}
+	}
+
+	if _, ok := s.nonces[nonce]; ok {
+		return false
+	}
+
+	s.nonces[nonce] = now.Add(2 * s.window)
+
+	return true
+}
+
+func (s *SignatureAuthenticator) Challenge() string {
+	return ""
+}
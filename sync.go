@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/libdns/libdns"
+)
+
+// SyncConfig holds the desired records of zones, which are
+// used by the plan and apply commands to reconcile the zones.
+type SyncConfig struct {
+	// Exclude holds glob patterns of hostnames that are ignored in all zones
+	Exclude []string `json:"exclude"`
+	// ExcludeDynamic ignores the hostnames from the hosts config, the host
+	// patterns of the users and tokens, the _acme-challenge records and the
+	// acme-dns records in the challenge zone. Hosts that can be updated by
+	// users or tokens without host patterns should be added to Exclude.
+	ExcludeDynamic bool                 `json:"exclude_dynamic"`
+	Zones          map[string]*SyncZone `json:"zones"`
+}
+
+type SyncZone struct {
+	// Module of the plugin when the zone is served by multiple plugins
+	Module string `json:"module"`
+	// File is a zone file (relative to the sync file) with records
+	File string `json:"file"`
+	// Records of the zone, in addition to the records from the file
+	Records []*ApiRecord `json:"records"`
+	// Exclude holds glob patterns of hostnames that are ignored in this zone
+	Exclude []string `json:"exclude"`
+}
+
+func ReadSyncConfig(file string) (*SyncConfig, error) {
+
+	var config = new(SyncConfig)
+
+	fd, err := os.Open(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer fd.Close()
+
+	if err := json.NewDecoder(fd).Decode(config); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	for name, zone := range config.Zones {
+
+		if nil == zone {
+			return nil, fmt.Errorf("%s: no records defined for zone '%s'", file, name)
+		}
+
+		if zone.File != "" && false == filepath.IsAbs(zone.File) {
+			zone.File = filepath.Join(filepath.Dir(file), zone.File)
+		}
+	}
+
+	return config, nil
+}
+
+// Desired returns the desired records of the zone
+func (s *SyncZone) Desired(zone string) ([]libdns.Record, error) {
+
+	var records = make([]libdns.Record, 0)
+
+	if s.File != "" {
+
+		fd, err := os.Open(s.File)
+
+		if err != nil {
+			return nil, err
+		}
+
+		defer fd.Close()
+
+		items, err := ParseZoneFile(fd, zone)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", s.File, err)
+		}
+
+		records = append(records, items...)
+	}
+
+	for _, item := range s.Records {
+
+		record, err := item.Record(zone, true)
+
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// SyncExcludes holds the glob patterns of the hostnames a sync will ignore
+type SyncExcludes []string
+
+func NewSyncExcludes(config *SyncConfig, server *ServerConfig, zone *SyncZone) SyncExcludes {
+
+	var excludes = make(SyncExcludes, 0)
+
+	excludes = append(excludes, config.Exclude...)
+	excludes = append(excludes, zone.Exclude...)
+
+	if config.ExcludeDynamic {
+
+		excludes = append(excludes, "_acme-challenge.*")
+
+		if nil != server {
+			excludes = append(excludes, dynamicExcludes(server)...)
+		}
+	}
+
+	return excludes
+}
+
+// dynamicExcludes returns the patterns of the hostnames that can be
+// updated through the server, which are the hosts with a policy, the
+// hosts the users and tokens may update and the acme-dns subdomains.
+func dynamicExcludes(server *ServerConfig) []string {
+
+	var excludes = make([]string, 0)
+
+	for pattern := range server.Hosts {
+		excludes = append(excludes, pattern)
+	}
+
+	if nil != server.Users {
+		for _, user := range *server.Users {
+			if nil != user {
+				excludes = append(excludes, user.Hosts...)
+			}
+		}
+	}
+
+	for _, token := range server.Tokens {
+		if nil != token {
+			excludes = append(excludes, token.Hosts...)
+		}
+	}
+
+	if server.ChallengeZone != "" {
+		excludes = append(excludes, "*."+strings.TrimSuffix(server.ChallengeZone, "."))
+	}
+
+	return excludes
+}
+
+// Match checks if the (absolute) hostname matches one of the patterns
+func (s SyncExcludes) Match(hostname string) bool {
+
+	hostname = strings.ToLower(strings.TrimSuffix(hostname, "."))
+
+	for _, pattern := range s {
+		if ok, _ := path.Match(strings.ToLower(strings.TrimSuffix(pattern, ".")), hostname); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Filter returns the records that are not excluded or managed by the provider
+func (s SyncExcludes) Filter(zone string, records []libdns.Record) []libdns.Record {
+
+	var filtered = make([]libdns.Record, 0, len(records))
+
+	for _, record := range records {
+
+		var rr = record.RR()
+
+		if isProviderManaged(rr, zone) || s.Match(libdns.AbsoluteName(rr.Name, zone)) {
+			continue
+		}
+
+		filtered = append(filtered, record)
+	}
+
+	return filtered
+}
+
+// PlanZone returns the changes needed to get the records of the
+// zone in sync with the desired records of the sync config.
+func PlanZone(ctx context.Context, entry *ZoneEntry, zone *SyncZone, excludes SyncExcludes) ([]*RecordChange, error) {
+
+	desired, err := zone.Desired(entry.Name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := entry.Plugin.GetRecords(ctx, entry.Name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return DiffRecords(entry.Name, excludes.Filter(entry.Name, current), excludes.Filter(entry.Name, desired), true), nil
+}
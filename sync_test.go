@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestNewSyncExcludes(t *testing.T) {
+
+	var server = &ServerConfig{
+		Users: &UserList{
+			"foo": &User{Name: "foo", Hosts: []string{"*.home.example.com"}},
+			"bar": nil,
+		},
+		Tokens:        TokenList{"ci": &Token{Hosts: []string{"ci.example.com"}}},
+		ChallengeZone: "acme.example.com.",
+		ServerUpdateConfig: ServerUpdateConfig{
+			Hosts: HostPolicies{"router.example.com": &HostPolicy{}},
+		},
+	}
+
+	var config = &SyncConfig{Exclude: []string{"legacy.example.com"}}
+	var zone = &SyncZone{Exclude: []string{"*.lab.example.com"}}
+
+	for _, dynamic := range []bool{false, true} {
+
+		config.ExcludeDynamic = dynamic
+
+		var excludes = NewSyncExcludes(config, server, zone)
+
+		for hostname, expected := range map[string]bool{
+			"legacy.example.com.":             true,
+			"x.lab.example.com":               true,
+			"www.example.com":                 false,
+			"_acme-challenge.www.example.com": dynamic,
+			"router.example.com":              dynamic,
+			"nas.home.example.com":            dynamic,
+			"CI.example.com":                  dynamic,
+			"d420c923.acme.example.com":       dynamic,
+			"acme.example.com":                false,
+		} {
+			if excludes.Match(hostname) != expected {
+				t.Errorf("dynamic %v: expected %s to match %v", dynamic, hostname, expected)
+			}
+		}
+	}
+}
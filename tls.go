@@ -0,0 +1,548 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pbergman/logger"
+)
+
+// TLSConfig holds the settings for serving https
+type TLSConfig struct {
+	Cert string `json:"cert"`
+	Key  string `json:"key"`
+	// MinVersion is the minimum tls version (1.2 or 1.3), defaults to 1.2
+	MinVersion string `json:"min_version"`
+	// Ciphers limits the cipher suites used for tls 1.2 (by name, like
+	// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256), where the tls 1.3 suites
+	// are not configurable.
+	Ciphers []string `json:"ciphers"`
+	// SelfSigned generates a self-signed certificate (and key) at the
+	// cert and key paths when they do not exist.
+	SelfSigned bool `json:"self_signed"`
+	// ACME requests (and renews) the certificate with an ACME server
+	ACME *ACMEConfig `json:"acme"`
+	// ClientCA is a bundle of CA certificates to verify client
+	// certificates, which enables client certificate authentication.
+	ClientCA string `json:"client_ca"`
+	// CRL is a file with (PEM or DER encoded) revocation lists of
+	// the client CA, certificates on these lists are refused.
+	CRL string `json:"crl"`
+}
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// NewTLSConfig creates the tls config for the server, where the certificate
+// is reloaded when the files change or when receiving a SIGHUP.
+func NewTLSConfig(ctx context.Context, config *TLSConfig, logger *logger.Logger, resolver *ZoneResolver) (*tls.Config, error) {
+
+	var cert, key = config.Cert, config.Key
+
+	if nil != config.ACME && cert == "" && key == "" {
+		cert, key = filepath.Join(config.ACME.StorageDir(), "cert.pem"), filepath.Join(config.ACME.StorageDir(), "key.pem")
+	}
+
+	if config.SelfSigned {
+		if err := createSelfSigned(cert, key, logger); err != nil {
+			return nil, err
+		}
+	}
+
+	if nil != config.ACME {
+
+		manager, err := NewACMEManager(config.ACME, cert, key, resolver, logger)
+
+		if err != nil {
+			return nil, err
+		}
+
+		// without a certificate the server can't start,
+		// so the first one is requested before starting
+		if _, err := os.Stat(cert); err != nil {
+			if err := manager.Obtain(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		go manager.Run(ctx)
+	}
+
+	var loader = &certificateLoader{cert: cert, key: key, logger: logger}
+
+	if err := loader.Reload(); err != nil {
+		return nil, err
+	}
+
+	go loader.Watch(ctx)
+
+	var ret = &tls.Config{
+		GetCertificate: loader.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+
+	if config.MinVersion != "" {
+
+		version, ok := tlsVersions[config.MinVersion]
+
+		if false == ok {
+			return nil, fmt.Errorf("unsupported tls version '%s' (supported: 1.2, 1.3)", config.MinVersion)
+		}
+
+		ret.MinVersion = version
+	}
+
+	if len(config.Ciphers) > 0 {
+
+		suites, err := parseCipherSuites(config.Ciphers)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ret.CipherSuites = suites
+	}
+
+	if config.ClientCA != "" {
+
+		authorities, err := readCertificates(config.ClientCA)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ret.ClientCAs = x509.NewCertPool()
+		// clients without certificate can still use the other
+		// authentication methods, so only verify when given
+		ret.ClientAuth = tls.VerifyClientCertIfGiven
+
+		for _, authority := range authorities {
+			ret.ClientCAs.AddCert(authority)
+		}
+
+		if config.CRL != "" {
+
+			var list = &revocationFile{path: config.CRL, authorities: authorities, logger: logger}
+
+			if err := list.reload(); err != nil {
+				return nil, err
+			}
+
+			ret.VerifyConnection = func(state tls.ConnectionState) error {
+
+				for _, chain := range state.VerifiedChains {
+					// the last certificate is the root, which is trusted
+					for i, c := 0, len(chain)-1; i < c; i++ {
+						if err := list.Check(chain[i]); err != nil {
+							return err
+						}
+					}
+				}
+
+				return nil
+			}
+		}
+	}
+
+	return ret, nil
+}
+
+// parseCipherSuites returns the ids of the (tls 1.2) cipher suites, where
+// tls 1.3 suites are refused as these are not configurable and would
+// otherwise be silently ignored.
+func parseCipherSuites(names []string) ([]uint16, error) {
+
+	var suites = make([]uint16, 0, len(names))
+
+	for _, name := range names {
+
+		var found *tls.CipherSuite
+
+		for _, suite := range tls.CipherSuites() {
+			if suite.Name == name {
+				found = suite
+				break
+			}
+		}
+
+		if nil == found {
+			return nil, fmt.Errorf("unsupported or insecure cipher suite '%s'", name)
+		}
+
+		if false == slices.Contains(found.SupportedVersions, tls.VersionTLS12) {
+			return nil, fmt.Errorf("cipher suite '%s' is a tls 1.3 suite, which is not configurable", name)
+		}
+
+		suites = append(suites, found.ID)
+	}
+
+	return suites, nil
+}
+
+// certificateLoader holds the server certificate, which is reloaded when
+// the files change (checked at most once a second) or on a SIGHUP. When
+// reloading fails, the previous certificate is kept.
+type certificateLoader struct {
+	cert        string
+	key         string
+	logger      *logger.Logger
+	lock        sync.Mutex
+	certificate *tls.Certificate
+	modTime     time.Time
+	checked     time.Time
+}
+
+func (c *certificateLoader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if time.Since(c.checked) >= time.Second {
+
+		c.checked = time.Now()
+
+		if c.changed() {
+			if err := c.load(); err != nil {
+				c.logger.Error(fmt.Sprintf("failed to reload certificate '%s': %s", c.cert, err.Error()))
+			}
+		}
+	}
+
+	return c.certificate, nil
+}
+
+func (c *certificateLoader) Reload() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.load()
+}
+
+// Watch reloads the certificate on SIGHUP until the context is done
+func (c *certificateLoader) Watch(ctx context.Context) {
+
+	var signals = make(chan os.Signal, 1)
+
+	signal.Notify(signals, syscall.SIGHUP)
+
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			if err := c.Reload(); err != nil {
+				c.logger.Error(fmt.Sprintf("failed to reload certificate '%s': %s", c.cert, err.Error()))
+			}
+		}
+	}
+}
+
+// lastModified returns the latest modification time of the cert and key file
+func (c *certificateLoader) lastModified() (time.Time, error) {
+
+	var last time.Time
+
+	for _, file := range []string{c.cert, c.key} {
+
+		stat, err := os.Stat(file)
+
+		if err != nil {
+			return last, err
+		}
+
+		if stat.ModTime().After(last) {
+			last = stat.ModTime()
+		}
+	}
+
+	return last, nil
+}
+
+func (c *certificateLoader) changed() bool {
+	modTime, err := c.lastModified()
+	return err == nil && false == modTime.Equal(c.modTime)
+}
+
+func (c *certificateLoader) load() error {
+
+	modTime, err := c.lastModified()
+
+	if err != nil {
+		return err
+	}
+
+	certificate, err := tls.LoadX509KeyPair(c.cert, c.key)
+
+	if err != nil {
+		return err
+	}
+
+	c.certificate = &certificate
+	c.modTime = modTime
+
+	if nil != certificate.Leaf {
+		c.logger.Debug(fmt.Sprintf("loaded certificate '%s' (%s, expires %s)", c.cert, strings.Join(certificate.Leaf.DNSNames, ", "), certificate.Leaf.NotAfter.Format(time.RFC3339)))
+	}
+
+	return nil
+}
+
+// createSelfSigned creates a self-signed certificate for the hostname of
+// this machine and localhost, when the cert and key file do not exist.
+// When only one of them exists an error is returned, as generating the
+// other would overwrite or mismatch an existing certificate or key.
+func createSelfSigned(cert, key string, logger *logger.Logger) error {
+
+	var missing []string
+
+	for _, file := range []string{cert, key} {
+
+		_, err := os.Stat(file)
+
+		if err != nil && false == errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		if err != nil {
+			missing = append(missing, file)
+		}
+	}
+
+	switch len(missing) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("not creating a self-signed certificate, '%s' does not exist while the other file does", missing[0])
+	}
+
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		return err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+
+	if err != nil {
+		return err
+	}
+
+	var template = &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "ddns-srv"},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(5, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+
+	if hostname, err := os.Hostname(); err == nil && hostname != "" && hostname != "localhost" {
+		template.Subject.CommonName = hostname
+		template.DNSNames = append(template.DNSNames, hostname)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &private.PublicKey, private)
+
+	if err != nil {
+		return err
+	}
+
+	data, err := x509.MarshalPKCS8PrivateKey(private)
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(key, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: data}), 0600); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return err
+	}
+
+	logger.Notice(fmt.Sprintf("created self-signed certificate '%s' for %s", cert, strings.Join(template.DNSNames, ", ")))
+
+	return nil
+}
+
+// readCertificates reads all certificates from a PEM encoded file
+func readCertificates(file string) ([]*x509.Certificate, error) {
+
+	data, err := os.ReadFile(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var certificates []*x509.Certificate
+
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		certificate, err := x509.ParseCertificate(block.Bytes)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+
+		certificates = append(certificates, certificate)
+	}
+
+	if len(certificates) == 0 {
+		return nil, fmt.Errorf("%s: no certificates found", file)
+	}
+
+	return certificates, nil
+}
+
+// revocationFile holds the revocation lists of a file that is reloaded
+// when changed, where only lists signed by one of the authorities are used.
+type revocationFile struct {
+	path        string
+	authorities []*x509.Certificate
+	logger      *logger.Logger
+	lock        sync.Mutex
+	lists       []*x509.RevocationList
+	modTime     time.Time
+	checked     time.Time
+}
+
+// Check returns an error when the certificate is on the revocation list of
+// its issuer, or when that list is past its next update. Certificates of
+// issuers without a revocation list are not checked.
+func (r *revocationFile) Check(certificate *x509.Certificate) error {
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if time.Since(r.checked) >= time.Second {
+		if err := r.reload(); err != nil {
+			r.logger.Error(fmt.Sprintf("failed to reload crl '%s': %s", r.path, err.Error()))
+		}
+	}
+
+	for _, list := range r.lists {
+
+		if false == bytes.Equal(list.RawIssuer, certificate.RawIssuer) {
+			continue
+		}
+
+		// a stale list could miss revocations, so the certificate is refused
+		if false == list.NextUpdate.IsZero() && time.Now().After(list.NextUpdate) {
+			return fmt.Errorf("revocation list of %s expired at %s", list.Issuer, list.NextUpdate.Format(time.RFC3339))
+		}
+
+		for _, entry := range list.RevokedCertificateEntries {
+			if entry.SerialNumber.Cmp(certificate.SerialNumber) == 0 {
+				return fmt.Errorf("certificate %s is revoked", certificate.Subject)
+			}
+		}
+	}
+
+	return nil
+}
+
+// reload will (re)read the file when it has changed, when it fails the
+// previous lists are kept.
+func (r *revocationFile) reload() error {
+
+	r.checked = time.Now()
+
+	stat, err := os.Stat(r.path)
+
+	if err != nil {
+		return err
+	}
+
+	if nil != r.lists && stat.ModTime().Equal(r.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(r.path)
+
+	if err != nil {
+		return err
+	}
+
+	var blocks [][]byte
+
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type == "X509 CRL" {
+			blocks = append(blocks, block.Bytes)
+		}
+	}
+
+	if len(blocks) == 0 {
+		blocks = append(blocks, data)
+	}
+
+	var lists = make([]*x509.RevocationList, 0, len(blocks))
+
+	for _, block := range blocks {
+
+		list, err := x509.ParseRevocationList(block)
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", r.path, err)
+		}
+
+		if err := r.verify(list); err != nil {
+			return fmt.Errorf("%s: %w", r.path, err)
+		}
+
+		if false == list.NextUpdate.IsZero() && time.Now().After(list.NextUpdate) {
+			r.logger.Warning(fmt.Sprintf("revocation list of %s in '%s' expired at %s, certificates of this issuer are refused", list.Issuer, r.path, list.NextUpdate.Format(time.RFC3339)))
+		}
+
+		lists = append(lists, list)
+	}
+
+	r.lists = lists
+	r.modTime = stat.ModTime()
+
+	r.logger.Debug(fmt.Sprintf("loaded %d revocation list(s) from '%s'", len(lists), r.path))
+
+	return nil
+}
+
+func (r *revocationFile) verify(list *x509.RevocationList) error {
+
+	for _, authority := range r.authorities {
+		if bytes.Equal(authority.RawSubject, list.RawIssuer) && nil == list.CheckSignatureFrom(authority) {
+			return nil
+		}
+	}
+
+	return errors.New("revocation list is not signed by one of the client authorities")
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseCipherSuites(t *testing.T) {
+
+	suites, err := parseCipherSuites([]string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(suites) != 2 || suites[0] != tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 || suites[1] != tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384 {
+		t.Errorf("unexpected suites %v", suites)
+	}
+
+	for _, name := range []string{"TLS_AES_128_GCM_SHA256", "TLS_RSA_WITH_RC4_128_SHA", "foo"} {
+		if _, err := parseCipherSuites([]string{name}); err == nil {
+			t.Errorf("expected %s to be refused", name)
+		}
+	}
+}
+
+func TestCreateSelfSigned(t *testing.T) {
+
+	var dir = t.TempDir()
+	var cert, key = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	if err := createSelfSigned(cert, key, testLogger()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tls.LoadX509KeyPair(cert, key); err != nil {
+		t.Fatal(err)
+	}
+
+	stat, _ := os.Stat(cert)
+
+	// existing files are kept
+	if err := createSelfSigned(cert, key, testLogger()); err != nil {
+		t.Fatal(err)
+	}
+
+	if x, _ := os.Stat(cert); false == x.ModTime().Equal(stat.ModTime()) {
+		t.Error("expected the certificate to be kept")
+	}
+
+	if err := os.Remove(key); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := createSelfSigned(cert, key, testLogger()); err == nil {
+		t.Error("expected an error when only the certificate exists")
+	}
+
+	if _, err := os.Stat(key); err == nil {
+		t.Error("expected no key to be created")
+	}
+}
+
+type testAuthority struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+}
+
+func newTestCertificate(t *testing.T, name string, serial int64, issuer *testAuthority) *testAuthority {
+
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var template = &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	var parent = &testAuthority{certificate: template, key: key}
+
+	if nil != issuer {
+		parent = issuer
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent.certificate, &key.PublicKey, parent.key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificate, err := x509.ParseCertificate(der)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testAuthority{certificate: certificate, key: key}
+}
+
+func newTestRevocationList(t *testing.T, issuer *testAuthority, next time.Time, serials ...int64) []byte {
+
+	t.Helper()
+
+	var template = &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-2 * time.Hour),
+		NextUpdate: next,
+	}
+
+	for _, serial := range serials {
+		template.RevokedCertificateEntries = append(template.RevokedCertificateEntries, x509.RevocationListEntry{SerialNumber: big.NewInt(serial), RevocationTime: time.Now()})
+	}
+
+	der, err := x509.CreateRevocationList(rand.Reader, template, issuer.certificate, issuer.key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})
+}
+
+func TestRevocationFileCheck(t *testing.T) {
+
+	var root = newTestCertificate(t, "root", 1, nil)
+	var intermediate = newTestCertificate(t, "intermediate", 2, root)
+	var revoked = newTestCertificate(t, "revoked", 3, root)
+	var leaf = newTestCertificate(t, "leaf", 4, intermediate)
+	var other = newTestCertificate(t, "other", 5, intermediate)
+	var authorities = []*x509.Certificate{root.certificate, intermediate.certificate}
+
+	for name, x := range map[string]struct {
+		lists    [][]byte
+		revoked  []*testAuthority
+		accepted []*testAuthority
+	}{
+		"root list": {
+			[][]byte{newTestRevocationList(t, root, time.Now().Add(time.Hour), 3)},
+			[]*testAuthority{revoked},
+			[]*testAuthority{intermediate, leaf, other},
+		},
+		"intermediate list": {
+			[][]byte{newTestRevocationList(t, root, time.Now().Add(time.Hour), 3), newTestRevocationList(t, intermediate, time.Now().Add(time.Hour), 5)},
+			[]*testAuthority{revoked, other},
+			[]*testAuthority{intermediate, leaf},
+		},
+		"expired list": {
+			[][]byte{newTestRevocationList(t, root, time.Now().Add(time.Hour)), newTestRevocationList(t, intermediate, time.Now().Add(-time.Hour))},
+			[]*testAuthority{leaf, other},
+			[]*testAuthority{intermediate, revoked},
+		},
+	} {
+		var file = filepath.Join(t.TempDir(), "crl.pem")
+		var data []byte
+
+		for _, list := range x.lists {
+			data = append(data, list...)
+		}
+
+		if err := os.WriteFile(file, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		var list = &revocationFile{path: file, authorities: authorities, logger: testLogger()}
+
+		if err := list.reload(); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		for _, certificate := range x.revoked {
+			if err := list.Check(certificate.certificate); err == nil {
+				t.Errorf("%s: expected %s to be refused", name, certificate.certificate.Subject)
+			}
+		}
+
+		for _, certificate := range x.accepted {
+			if err := list.Check(certificate.certificate); err != nil {
+				t.Errorf("%s: expected %s to be accepted, got %s", name, certificate.certificate.Subject, err)
+			}
+		}
+	}
+}
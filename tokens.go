@@ -0,0 +1,122 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TokenPrefix is the prefix of all issued tokens, which makes them
+// recognizable and distinguishable from other bearer credentials.
+const TokenPrefix = "ddns_"
+
+// Token is an API token as defined in the config, where only the sha256
+// (hex) hash of the token is stored. A token is revoked by removing it
+// from the config or by setting revoked to true.
+type Token struct {
+	Hash string `json:"hash"`
+	// Scopes of the token, which defaults to update and read
+	Scopes []Role `json:"scopes,omitempty"`
+	// Hosts holds glob patterns of the hostnames the token may update
+	Hosts []string `json:"hosts,omitempty"`
+	// Zones the token may update hostnames in
+	Zones []string `json:"zones,omitempty"`
+	// QueryTTL allows the token to set the TTL of update requests
+	QueryTTL bool `json:"query_ttl,omitempty"`
+	// Expires is the time after which the token is no longer valid
+	Expires *time.Time `json:"expires,omitempty"`
+	Revoked bool       `json:"revoked,omitempty"`
+}
+
+// User returns the token as user, so the same ACL and
+// role checks apply as for users with basic authentication
+func (t *Token) User(id string) *User {
+	return &User{
+		Name:     "token:" + id,
+		Hosts:    t.Hosts,
+		Zones:    t.Zones,
+		Roles:    t.Scopes,
+		QueryTTL: t.QueryTTL,
+	}
+}
+
+func (t *Token) IsValid(now time.Time) bool {
+	return false == t.Revoked && (nil == t.Expires || now.Before(*t.Expires))
+}
+
+type TokenList map[string]*Token
+
+// NewToken creates a new random token and returns the token with its hash
+func NewToken() (string, string, error) {
+
+	var buf = make([]byte, 32)
+
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+
+	var token = TokenPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	var sum = sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func NewTokenStore(tokens TokenList) *TokenStore {
+	return &TokenStore{tokens: tokens}
+}
+
+// TokenStore authenticates requests with a bearer token from the
+// Authorization header or the token query parameter.
+type TokenStore struct {
+	tokens TokenList
+}
+
+func (s *TokenStore) Authenticate(request *http.Request) (*User, error) {
+
+	var token = getBearerToken(request)
+
+	if token == "" || false == strings.HasPrefix(token, TokenPrefix) {
+		return nil, nil
+	}
+
+	var hash = []byte(hashToken(token))
+	var now = time.Now()
+
+	for id, entry := range s.tokens {
+		if nil != entry && subtle.ConstantTimeCompare(hash, []byte(strings.ToLower(entry.Hash))) == 1 {
+
+			if false == entry.IsValid(now) {
+				return nil, errors.New("token expired or revoked")
+			}
+
+			return entry.User(id), nil
+		}
+	}
+
+	return nil, errors.New("invalid token")
+}
+
+func (s *TokenStore) Challenge() string {
+	return `Bearer realm="DDNS Server"`
+}
+
+// getBearerToken returns the token from the Authorization
+// header or, when not set, from the token query parameter.
+func getBearerToken(request *http.Request) string {
+
+	if value := request.Header.Get("Authorization"); len(value) > 7 && strings.EqualFold(value[:7], "bearer ") {
+		return strings.TrimSpace(value[7:])
+	}
+
+	return request.URL.Query().Get("token")
+}
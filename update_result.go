@@ -0,0 +1,349 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+)
+
+// The dyndns2 return codes
+//
+// see https://help.dyn.com/remote-access-api/return-codes/
+const (
+	UpdateGood     = "good"
+	UpdateNoChange = "nochg"
+	UpdateBadAuth  = "badauth"
+	UpdateNotFqdn  = "notfqdn"
+	UpdateNoHost   = "nohost"
+	UpdateNumHost  = "numhost"
+	UpdateAbuse    = "abuse"
+	UpdateBadAgent = "badagent"
+	UpdateDnsErr   = "dnserr"
+	UpdateServErr  = "911"
+)
+
+// UpdateError is an error that results in a single return code
+// for the whole request instead of a code per hostname.
+type UpdateError struct {
+	Code string
+	Err  error
+}
+
+func (u *UpdateError) Error() string {
+
+	if nil == u.Err {
+		return u.Code
+	}
+
+	return u.Err.Error()
+}
+
+func (u *UpdateError) Unwrap() error {
+	return u.Err
+}
+
+func NewUpdateError(code string, err error) error {
+	return &UpdateError{Code: code, Err: err}
+}
+
+func (a UpdateAddrs) MarshalJSON() ([]byte, error) {
+
+	var list = make([]string, 0, 2)
+
+	for _, addr := range []netip.Addr{a.V4, a.V6} {
+		if addr.IsValid() {
+			list = append(list, addr.String())
+		}
+	}
+
+	return json.Marshal(list)
+}
+
+type UpdateStatus struct {
+	Hostname string      `json:"hostname"`
+	Code     string      `json:"status"`
+	Addrs    UpdateAddrs `json:"addresses"`
+	Zone     string      `json:"zone,omitempty"`
+	Module   string      `json:"module,omitempty"`
+	Error    string      `json:"error,omitempty"`
+	// Providers holds the result per plugin when updates are fanned out
+	Providers []*ProviderStatus `json:"providers,omitempty"`
+}
+
+// ProviderStatus is the result of a host update for a single plugin
+type ProviderStatus struct {
+	Module string `json:"module"`
+	Zone   string `json:"zone"`
+	Code   string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	index  int
+}
+
+func (u *UpdateStatus) String() string {
+
+	if u.Addrs.IsValid() && (u.Code == UpdateGood || u.Code == UpdateNoChange) {
+		return u.Code + " " + u.Addrs.String()
+	}
+
+	return u.Code
+}
+
+type UpdateResult struct {
+	items []*UpdateStatus
+	lock  sync.Mutex
+}
+
+func (u *UpdateResult) WriteTo(w io.Writer) (n int64, err error) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	var lines = make([]string, len(u.items))
+
+	for i, c := 0, len(u.items); i < c; i++ {
+		lines[i] = u.items[i].String()
+	}
+
+	x, err := w.Write([]byte(strings.Join(lines, "\n")))
+
+	if err != nil {
+		return int64(x), err
+	}
+
+	return int64(x), nil
+}
+
+func (u *UpdateResult) MarshalJSON() ([]byte, error) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	return json.Marshal(&updateResponse{Hosts: u.items})
+}
+
+func (u *UpdateResult) Set(idx int, code string) {
+	u.lock.Lock()
+	u.items[idx].Code = code
+	u.items[idx].Error = ""
+	u.lock.Unlock()
+}
+
+// Code returns the return code of the host
+func (u *UpdateResult) Code(idx int) string {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	return u.items[idx].Code
+}
+
+// SetError sets the return code for a host with the error detail
+// that is reported to the clients that requested a json response.
+func (u *UpdateResult) SetError(idx int, code string, err error) {
+	u.lock.Lock()
+	u.items[idx].Code = code
+	u.items[idx].Error = err.Error()
+	u.lock.Unlock()
+}
+
+// SetAddrs sets the addresses that are reported back to
+// the client when a host was updated or unchanged.
+func (u *UpdateResult) SetAddrs(idx int, addrs UpdateAddrs) {
+	u.lock.Lock()
+	u.items[idx].Addrs = addrs
+	u.lock.Unlock()
+}
+
+// SetZone sets the zone and module that is responsible for the host
+func (u *UpdateResult) SetZone(idx int, zone *ZoneEntry) {
+	u.lock.Lock()
+	u.items[idx].Zone = zone.Name
+	u.items[idx].Module = zone.Plugin.Module().Path
+	u.lock.Unlock()
+}
+
+// AddProvider adds a plugin the update of a host is written to
+func (u *UpdateResult) AddProvider(idx int, zone *ZoneEntry) {
+	u.lock.Lock()
+	u.items[idx].Providers = append(u.items[idx].Providers, &ProviderStatus{
+		Module: zone.Plugin.Module().Path,
+		Zone:   zone.Name,
+		Code:   UpdateServErr,
+		index:  zone.Index,
+	})
+	u.lock.Unlock()
+}
+
+// AddFailedProvider adds a plugin that could not list its zones as a failed
+// provider of a host, so it is counted as a failure when combining the results.
+func (u *UpdateResult) AddFailedProvider(idx int, plugin PluginProvider, index int, err error) {
+	u.lock.Lock()
+	u.items[idx].Providers = append(u.items[idx].Providers, &ProviderStatus{
+		Module: plugin.Module().Path,
+		Code:   UpdateDnsErr,
+		Error:  "error listing zones: " + err.Error(),
+		index:  index,
+	})
+	u.lock.Unlock()
+}
+
+// SetProvider sets the return code of a host for the plugin with given
+// index, where a nil error resets the error of a previous code.
+func (u *UpdateResult) SetProvider(idx int, provider int, code string, err error) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	for _, status := range u.items[idx].Providers {
+		if status.index == provider {
+			status.Code, status.Error = code, ""
+
+			if nil != err {
+				status.Error = err.Error()
+			}
+		}
+	}
+}
+
+// Combine sets the return code of the hosts that were updated with multiple
+// plugins from the results per plugin. With FanOutAll a failure of any plugin
+// results in the error of that plugin and with FanOutAny only when all failed.
+func (u *UpdateResult) Combine(mode FanOutMode) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	for _, item := range u.items {
+
+		if len(item.Providers) == 0 {
+			continue
+		}
+
+		var good, succeeded bool
+		var failed = make([]*ProviderStatus, 0)
+
+		for _, status := range item.Providers {
+			switch status.Code {
+			case UpdateGood:
+				good, succeeded = true, true
+			case UpdateNoChange:
+				succeeded = true
+			default:
+				failed = append(failed, status)
+			}
+		}
+
+		switch {
+		case len(failed) > 0 && (mode == FanOutAll || false == succeeded):
+
+			var errs = make([]string, len(failed))
+
+			for i, c := 0, len(failed); i < c; i++ {
+				errs[i] = failed[i].Module + ": " + failed[i].Error
+			}
+
+			item.Code, item.Error = failed[0].Code, strings.Join(errs, ", ")
+		case good:
+			item.Code, item.Error = UpdateGood, ""
+		default:
+			item.Code, item.Error = UpdateNoChange, ""
+		}
+	}
+}
+
+func NewUpdateResult(hosts []string) *UpdateResult {
+	var result = &UpdateResult{
+		items: make([]*UpdateStatus, len(hosts)),
+	}
+
+	for i, c := 0, len(hosts); i < c; i++ {
+		// when nothing sets the status something went wrong
+		// so defaults to a server error for that host
+		result.items[i] = &UpdateStatus{Hostname: hosts[i], Code: UpdateServErr}
+	}
+
+	return result
+}
+
+type updateResponse struct {
+	Status string          `json:"status,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	Hosts  []*UpdateStatus `json:"hosts,omitempty"`
+}
+
+// isJsonRequest checks if the client asked for a json response, with
+// a format=json query parameter or by accepting application/json.
+func isJsonRequest(request *http.Request) bool {
+
+	if format := request.URL.Query().Get("format"); format != "" {
+		return strings.EqualFold(format, "json")
+	}
+
+	for _, value := range request.Header.Values("accept") {
+		for _, x := range strings.Split(value, ",") {
+			if mime, _, _ := strings.Cut(x, ";"); strings.EqualFold(strings.TrimSpace(mime), "application/json") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// writeUpdateResult writes the per host result as text (a line for every
+// host as defined by the dyndns2 protocol) or as json.
+func writeUpdateResult(response http.ResponseWriter, request *http.Request, result *UpdateResult) {
+
+	if isJsonRequest(request) {
+		response.Header().Set("content-type", "application/json")
+		_ = json.NewEncoder(response).Encode(result)
+		return
+	}
+
+	response.Header().Set("content-type", "text/plain; charset=utf-8")
+	_, _ = result.WriteTo(response)
+}
+
+// writeUpdateError writes the return code of an error that applies to the
+// whole request. The dyndns2 clients expect the codes in the body so these
+// are returned with a 200 status (except for badauth) and for json clients
+// a matching http status is used.
+func writeUpdateError(response http.ResponseWriter, request *http.Request, err error) {
+
+	var code = UpdateServErr
+	var status = http.StatusOK
+	var ref *UpdateError
+
+	if errors.As(err, &ref) {
+		code = ref.Code
+	}
+
+	if code == UpdateBadAuth {
+		status = http.StatusUnauthorized
+	}
+
+	if isJsonRequest(request) {
+
+		switch code {
+		case UpdateNotFqdn, UpdateNumHost, UpdateBadAgent:
+			status = http.StatusBadRequest
+		case UpdateAbuse:
+			status = http.StatusTooManyRequests
+		case UpdateNoHost:
+			status = http.StatusForbidden
+		case UpdateServErr, UpdateDnsErr:
+			status = http.StatusInternalServerError
+		}
+
+		response.Header().Set("content-type", "application/json")
+		response.WriteHeader(status)
+
+		_ = json.NewEncoder(response).Encode(&updateResponse{Status: code, Error: err.Error()})
+
+		return
+	}
+
+	response.Header().Set("content-type", "text/plain; charset=utf-8")
+	response.WriteHeader(status)
+
+	_, _ = response.Write([]byte(code))
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUpdateResultCombine(t *testing.T) {
+
+	var a = &ZoneEntry{Name: "example.com", Plugin: newTestProvider("a"), Index: 0}
+	var b = &ZoneEntry{Name: "example.com", Plugin: newTestProvider("b"), Index: 1}
+
+	for name, x := range map[string]struct {
+		mode  FanOutMode
+		codes []string
+		code  string
+	}{
+		"all good":               {FanOutAll, []string{UpdateGood, UpdateGood}, UpdateGood},
+		"all unchanged":          {FanOutAll, []string{UpdateNoChange, UpdateNoChange}, UpdateNoChange},
+		"all partly changed":     {FanOutAll, []string{UpdateNoChange, UpdateGood}, UpdateGood},
+		"all one failed":         {FanOutAll, []string{UpdateGood, UpdateDnsErr}, UpdateDnsErr},
+		"all one not written":    {FanOutAll, []string{UpdateGood, ""}, UpdateServErr},
+		"any one failed":         {FanOutAny, []string{UpdateDnsErr, UpdateGood}, UpdateGood},
+		"any one failed nochg":   {FanOutAny, []string{UpdateNoChange, UpdateDnsErr}, UpdateNoChange},
+		"any all failed":         {FanOutAny, []string{UpdateDnsErr, UpdateDnsErr}, UpdateDnsErr},
+		"any first failure wins": {FanOutAny, []string{UpdateServErr, UpdateDnsErr}, UpdateServErr},
+	} {
+
+		var result = NewUpdateResult([]string{"www.example.com"})
+
+		result.AddProvider(0, a)
+		result.AddProvider(0, b)
+
+		for idx, code := range x.codes {
+
+			if code == "" {
+				continue
+			}
+
+			var err error
+
+			if code != UpdateGood && code != UpdateNoChange {
+				err = errors.New("failed")
+			}
+
+			result.SetProvider(0, idx, code, err)
+		}
+
+		result.Combine(x.mode)
+
+		if code := result.Code(0); code != x.code {
+			t.Errorf("%s: expected %s got %s", name, x.code, code)
+		}
+	}
+}
+
+func TestUpdateResultCombineFailedPlugin(t *testing.T) {
+
+	for _, mode := range []FanOutMode{FanOutAll, FanOutAny} {
+
+		var result = NewUpdateResult([]string{"www.example.com", "other.example.com"})
+
+		result.AddFailedProvider(0, newTestProvider("a"), 0, errors.New("unavailable"))
+		result.Combine(mode)
+
+		if code := result.Code(0); code != UpdateDnsErr {
+			t.Errorf("%s: expected %s got %s", mode, UpdateDnsErr, code)
+		}
+
+		if result.items[0].Error != "a: error listing zones: unavailable" {
+			t.Errorf("%s: unexpected error '%s'", mode, result.items[0].Error)
+		}
+
+		// hosts without providers keep their code
+		if code := result.Code(1); code != UpdateServErr {
+			t.Errorf("%s: expected %s got %s", mode, UpdateServErr, code)
+		}
+	}
+}
+
+func TestIsJsonRequest(t *testing.T) {
+
+	for name, x := range map[string]struct {
+		query  string
+		accept []string
+		json   bool
+	}{
+		"default":            {"", nil, false},
+		"format":             {"?format=json", nil, true},
+		"invalid format":     {"?format=foo", []string{"application/json"}, false},
+		"format over accept": {"?format=text", []string{"application/json"}, false},
+		"accept":             {"", []string{"application/json"}, true},
+		"accept not first":   {"", []string{"text/plain, application/json;q=0.5"}, true},
+		"accept headers":     {"", []string{"text/plain", "application/json"}, true},
+		"accept other":       {"", []string{"text/csv"}, false},
+	} {
+		var request = httptest.NewRequest("GET", "/nic/update"+x.query, nil)
+
+		for _, value := range x.accept {
+			request.Header.Add("Accept", value)
+		}
+
+		if isJsonRequest(request) != x.json {
+			t.Errorf("%s: expected %v", name, x.json)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pbergman/logger"
+)
+
+type Role string
+
+const (
+	// RoleUpdate allows updating records with the dyndns2 protocol
+	RoleUpdate Role = "update"
+	// RoleRead allows reading zones and records
+	RoleRead Role = "read"
+	// RoleACME allows managing dns-01 challenge records
+	RoleACME Role = "acme"
+	// RoleAdmin allows everything
+	RoleAdmin Role = "admin"
+)
+
+func (r Role) IsValid() bool {
+	return r == RoleUpdate || r == RoleRead || r == RoleACME || r == RoleAdmin
+}
+
+// User is a user as defined in the config, which can be defined as
+// just the password (so without any restrictions) or as an object
+// that limits the hosts and zones the user is allowed to update.
+type User struct {
+	Name     string `json:"-"`
+	Password string `json:"password"`
+	// Hosts holds glob patterns of the hostnames the user may update
+	Hosts []string `json:"hosts,omitempty"`
+	// Zones the user may update hostnames in
+	Zones []string `json:"zones,omitempty"`
+	// Sources limits the networks the user can connect from
+	Sources *IPPrefixList `json:"sources,omitempty"`
+	// DetectedIpOnly ignores the myip (and myipv6) parameters and
+	// will always use the address detected for the client
+	DetectedIpOnly bool `json:"detected_ip_only,omitempty"`
+	// QueryTTL allows the user to set the TTL with the ttl parameter
+	// of an update request, when enabled with query_ttl in the config
+	QueryTTL bool `json:"query_ttl,omitempty"`
+	// Roles of the user, which defaults to update and read
+	Roles []Role `json:"roles,omitempty"`
+	// Certificates holds the identities (subject, common name or
+	// alternative names) of client certificates that map to this user
+	Certificates []string `json:"certificates,omitempty"`
+}
+
+// HasRole checks if the user has given role, where admin has all roles
+// and a user without roles defaults to the update and read roles.
+func (u *User) HasRole(role Role) bool {
+
+	if nil == u {
+		return true
+	}
+
+	if len(u.Roles) == 0 {
+		return role == RoleUpdate || role == RoleRead
+	}
+
+	for _, x := range u.Roles {
+		if x == role || x == RoleAdmin {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (u *User) UnmarshalJSON(data []byte) error {
+
+	var password string
+
+	if err := json.Unmarshal(data, &password); err == nil {
+		u.Password = password
+		return nil
+	}
+
+	type user User
+
+	return json.Unmarshal(data, (*user)(u))
+}
+
+// AllowsHost checks if the user may update the hostname which is
+// allowed when it matches one of the hostname globs or when the zone
+// is in the zone list. A user without hosts or zones has no restrictions.
+func (u *User) AllowsHost(hostname, zone string) bool {
+
+	if nil == u || (len(u.Hosts) == 0 && len(u.Zones) == 0) {
+		return true
+	}
+
+	hostname = strings.ToLower(strings.TrimSuffix(hostname, "."))
+
+	for _, pattern := range u.Hosts {
+		if ok, _ := path.Match(strings.ToLower(strings.TrimSuffix(pattern, ".")), hostname); ok {
+			return true
+		}
+	}
+
+	for _, name := range u.Zones {
+		if strings.EqualFold(strings.TrimSuffix(name, "."), strings.TrimSuffix(zone, ".")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowsTTL checks if the user may set the TTL of an update request, which
+// is allowed for users with query_ttl or the admin role. Without users (so
+// no authentication) anyone can set the TTL.
+func (u *User) AllowsTTL() bool {
+
+	if nil == u || u.QueryTTL {
+		return true
+	}
+
+	for _, x := range u.Roles {
+		if x == RoleAdmin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowsSource checks if the user may connect from given address
+func (u *User) AllowsSource(addr netip.Addr) bool {
+
+	if nil == u || nil == u.Sources || len(*u.Sources) == 0 {
+		return true
+	}
+
+	return u.Sources.Contains(addr.Unmap())
+}
+
+type UserList map[string]*User
+
+func (u *UserList) UnmarshalJSON(data []byte) error {
+
+	var users map[string]*User
+
+	if err := json.Unmarshal(data, &users); err != nil {
+		return err
+	}
+
+	for name, user := range users {
+		if nil != user {
+			user.Name = name
+		}
+	}
+
+	*u = users
+
+	return nil
+}
+
+// UserStore authenticates users against the users from the config and
+// (optionally) a htpasswd style file. Users from the config take precedence
+// and a user defined in both without a password in the config will use the
+// password from the file, which makes it possible to define the restrictions
+// for a user in the config and manage the passwords in the file.
+type UserStore struct {
+	users UserList
+	file  *PasswdFile
+}
+
+func NewUserStore(users *UserList, file *PasswdFile) *UserStore {
+	var store = &UserStore{file: file}
+
+	if nil != users {
+		store.users = *users
+	}
+
+	return store
+}
+
+// Authenticate authenticates requests with basic authentication or
+// the X-Api-User and X-Api-Key headers as used by acme-dns clients.
+func (s *UserStore) Authenticate(request *http.Request) (*User, error) {
+
+	name, pass, ok := getCredentials(request)
+
+	if false == ok {
+		return nil, nil
+	}
+
+	if user := s.Verify(name, pass); nil != user {
+		return user, nil
+	}
+
+	return nil, errors.New("invalid credentials")
+}
+
+func getCredentials(request *http.Request) (string, string, bool) {
+
+	if name, pass, ok := request.BasicAuth(); ok {
+		return name, pass, true
+	}
+
+	if name := request.Header.Get("X-Api-User"); name != "" {
+		return name, request.Header.Get("X-Api-Key"), true
+	}
+
+	return "", "", false
+}
+
+func (s *UserStore) Challenge() string {
+	return `Basic realm="DDNS Server"`
+}
+
+// Verify returns the user when the credentials are valid and nil otherwise
+func (s *UserStore) Verify(name, pass string) *User {
+
+	var user *User
+	var stored string
+
+	if v, ok := s.users[name]; ok && nil != v {
+		user, stored = v, v.Password
+	}
+
+	if stored == "" && nil != s.file {
+		if hash, ok := s.file.Lookup(name); ok {
+			stored = hash
+
+			if nil == user {
+				user = &User{Name: name}
+			}
+		}
+	}
+
+	if nil == user || stored == "" {
+		// still verify (with the default algorithm of the passwd command)
+		// so unknown users can not be told apart by the response time
+		VerifyPassword(dummyPasswordHash, pass)
+		return nil
+	}
+
+	if false == VerifyPassword(stored, pass) {
+		return nil
+	}
+
+	return user
+}
+
+// PasswdFile is a htpasswd style file (a user:hash per line) that is
+// reloaded when the file changes.
+type PasswdFile struct {
+	path    string
+	logger  *logger.Logger
+	lock    sync.Mutex
+	entries map[string]string
+	modTime time.Time
+	checked time.Time
+}
+
+func NewPasswdFile(path string, logger *logger.Logger) *PasswdFile {
+	return &PasswdFile{path: path, logger: logger}
+}
+
+func (p *PasswdFile) Lookup(name string) (string, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.reload()
+
+	value, ok := p.entries[name]
+
+	return value, ok
+}
+
+// reload will (re)read the file when it has changed since the last read,
+// which is checked at most once a second. When the file could not be read
+// the previous entries are kept.
+func (p *PasswdFile) reload() {
+
+	if time.Since(p.checked) < time.Second {
+		return
+	}
+
+	p.checked = time.Now()
+
+	stat, err := os.Stat(p.path)
+
+	if err != nil {
+		p.logger.Error(fmt.Sprintf("failed to stat users file '%s': %s", p.path, err.Error()))
+		return
+	}
+
+	if nil != p.entries && stat.ModTime().Equal(p.modTime) {
+		return
+	}
+
+	data, err := os.ReadFile(p.path)
+
+	if err != nil {
+		p.logger.Error(fmt.Sprintf("failed to read users file '%s': %s", p.path, err.Error()))
+		return
+	}
+
+	var entries = make(map[string]string)
+
+	for _, line := range strings.Split(string(data), "\n") {
+
+		if line = strings.TrimSpace(line); line == "" || line[0] == '#' {
+			continue
+		}
+
+		if name, hash, ok := strings.Cut(line, ":"); ok {
+			entries[name] = hash
+		}
+	}
+
+	p.entries = entries
+	p.modTime = stat.ModTime()
+
+	p.logger.Debug(fmt.Sprintf("loaded %d user(s) from '%s'", len(entries), p.path))
+}
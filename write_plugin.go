@@ -7,7 +7,12 @@ import (
 	"text/tabwriter"
 )
 
-func WritePlugin(ctx context.Context, plugins []PluginProvider, lock WaitableLocker, stdout, stderr io.Writer, modules ...string) {
+func WritePlugin(ctx context.Context, plugins []PluginProvider, lock WaitableLocker, format Format, stdout, stderr io.Writer, modules ...string) {
+
+	if format != FormatTable {
+		encodePlugins(plugins, NewRowEncoder(format, stdout, pluginColumns), stderr, modules...)
+		return
+	}
 
 	var tab = tabwriter.NewWriter(stdout, 0, 2, 2, ' ', 0)
 
@@ -30,3 +35,29 @@ func WritePlugin(ctx context.Context, plugins []PluginProvider, lock WaitableLoc
 	fmt.Fprint(tab, "\n")
 	tab.Flush()
 }
+
+func encodePlugins(plugins []PluginProvider, encoder RowEncoder, stderr io.Writer, modules ...string) {
+
+	for _, provider := range plugins {
+
+		if len(modules) > 0 && false == inSlice(modules, provider.Module().Path) {
+			continue
+		}
+
+		var row = &PluginRow{
+			Module:  provider.Module().Path,
+			Version: provider.Module().Version,
+			Sum:     provider.Module().Sum,
+		}
+
+		if nil != provider.Module().Replace {
+			row.Replace = provider.Module().Replace.Path + "@" + provider.Module().Replace.Version
+		}
+
+		_ = encoder.Encode(row)
+	}
+
+	if err := encoder.Close(); err != nil {
+		_, _ = fmt.Fprintln(stderr, err.Error())
+	}
+}
@@ -17,12 +17,23 @@ import (
 
 type zoneRecords map[string][]*libdns.RR
 
-func WriteRecords(ctx context.Context, plugins []PluginProvider, lock WaitableLocker, stdout, stderr io.Writer, modules ...string) {
+func WriteRecords(ctx context.Context, resolver *ZoneResolver, lock WaitableLocker, format Format, stdout, stderr io.Writer, modules ...string) {
 
 	var mapped sync.Map
 	var sizes [4]uint64
 
-	for _, provider := range plugins {
+	table, err := resolver.Fetch(ctx, lock, modules...)
+
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, err.Error())
+	}
+
+	if format != FormatTable {
+		encodeRecords(ctx, resolver, table, lock, NewRowEncoder(format, stdout, recordColumns), stderr, modules...)
+		return
+	}
+
+	for _, provider := range resolver.Plugins() {
 
 		if len(modules) > 0 && false == inSlice(modules, provider.Module().Path) {
 			continue
@@ -30,7 +41,7 @@ func WriteRecords(ctx context.Context, plugins []PluginProvider, lock WaitableLo
 
 		lock.Lock()
 
-		go fetchRecords(ctx, lock, provider, &mapped, &sizes, stderr)
+		go fetchRecords(ctx, lock, provider, table.Zones(provider), &mapped, &sizes, stderr)
 	}
 
 	lock.Wait()
@@ -38,17 +49,10 @@ func WriteRecords(ctx context.Context, plugins []PluginProvider, lock WaitableLo
 	writeRecords(&mapped, sizes, stdout)
 }
 
-func fetchRecords(ctx context.Context, lock sync.Locker, provider PluginProvider, mapped *sync.Map, sizes *[4]uint64, stderr io.Writer) {
+func fetchRecords(ctx context.Context, lock sync.Locker, provider PluginProvider, zones []*ZoneEntry, mapped *sync.Map, sizes *[4]uint64, stderr io.Writer) {
 
 	defer lock.Unlock()
 
-	zones, err := provider.ListZones(ctx)
-
-	if err != nil {
-		_, _ = fmt.Fprintf(stderr, "%s: error listing zones: %v\n", provider.Module().Path, err)
-		return
-	}
-
 	var records = make(zoneRecords)
 
 	for _, zone := range zones {
@@ -57,11 +61,12 @@ func fetchRecords(ctx context.Context, lock sync.Locker, provider PluginProvider
 
 		if err != nil {
 
-			if false == errors.Is(err, context.Canceled) {
-				_, _ = fmt.Fprintf(stderr, "%s: error listing records for zone %q: %v\n", provider.Module().Path, zone.Name, err)
+			if errors.Is(err, context.Canceled) {
+				return
 			}
 
-			return
+			_, _ = fmt.Fprintf(stderr, "%s: error listing records for zone %q: %v\n", provider.Module().Path, zone.Name, err)
+			continue
 		}
 
 		for _, record := range items {
@@ -90,6 +95,8 @@ func fetchRecords(ctx context.Context, lock sync.Locker, provider PluginProvider
 
 			records[zone.Name] = append(records[zone.Name], &rr)
 		}
+
+		sortRecords(records[zone.Name])
 	}
 
 	if len(records) > 0 {
@@ -97,6 +104,98 @@ func fetchRecords(ctx context.Context, lock sync.Locker, provider PluginProvider
 	}
 }
 
+// encodeRecords writes the records of every zone as rows, which are
+// encoded as soon as the records of a zone are fetched.
+func encodeRecords(ctx context.Context, resolver *ZoneResolver, table *ZoneTable, lock WaitableLocker, encoder RowEncoder, stderr io.Writer, modules ...string) {
+
+	for _, provider := range resolver.Plugins() {
+
+		if len(modules) > 0 && false == inSlice(modules, provider.Module().Path) {
+			continue
+		}
+
+		lock.Lock()
+
+		go func(provider PluginProvider, zones []*ZoneEntry) {
+
+			defer lock.Unlock()
+
+			for _, zone := range zones {
+
+				items, err := provider.GetRecords(ctx, zone.Name)
+
+				if err != nil {
+
+					if errors.Is(err, context.Canceled) {
+						return
+					}
+
+					_, _ = fmt.Fprintf(stderr, "%s: error listing records for zone %q: %v\n", provider.Module().Path, zone.Name, err)
+					continue
+				}
+
+				for _, row := range newRecordRows(provider, zone.Name, items) {
+					if err := encoder.Encode(row); err != nil {
+						_, _ = fmt.Fprintln(stderr, err.Error())
+						return
+					}
+				}
+			}
+		}(provider, table.Zones(provider))
+	}
+
+	lock.Wait()
+
+	if err := encoder.Close(); err != nil {
+		_, _ = fmt.Fprintln(stderr, err.Error())
+	}
+}
+
+// newRecordRows converts the records of a zone to rows with absolute
+// names, sorted by name, type and data.
+func newRecordRows(provider PluginProvider, zone string, records []libdns.Record) []*RecordRow {
+
+	var items = make([]*libdns.RR, len(records))
+
+	for idx, record := range records {
+		rr := record.RR()
+		rr.Name = libdns.AbsoluteName(rr.Name, zone)
+		items[idx] = &rr
+	}
+
+	sortRecords(items)
+
+	var rows = make([]*RecordRow, len(items))
+
+	for idx, rr := range items {
+		rows[idx] = &RecordRow{
+			Module: provider.Module().Path,
+			Zone:   zone,
+			Name:   rr.Name,
+			Type:   rr.Type,
+			TTL:    int64(rr.TTL.Round(time.Second) / time.Second),
+			Data:   rr.Data,
+		}
+	}
+
+	return rows
+}
+
+func sortRecords(records []*libdns.RR) {
+	sort.SliceStable(records, func(i, j int) bool {
+
+		if records[i].Name != records[j].Name {
+			return records[i].Name < records[j].Name
+		}
+
+		if records[i].Type != records[j].Type {
+			return records[i].Type < records[j].Type
+		}
+
+		return records[i].Data < records[j].Data
+	})
+}
+
 func writeRecords(mapped *sync.Map, sizes [4]uint64, writer io.Writer) {
 
 	var maxHead uint64
@@ -10,50 +10,68 @@ import (
 	"github.com/libdns/libdns"
 )
 
-func WriteShort(ctx context.Context, plugins []PluginProvider, lock WaitableLocker, stdout, stderr io.Writer, rtype, hostname string) {
+func WriteShort(ctx context.Context, resolver *ZoneResolver, lock WaitableLocker, format Format, stdout, stderr io.Writer, rtype, hostname string) {
 
-	for _, provider := range plugins {
+	table, err := resolver.Fetch(ctx, lock)
+
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, err.Error())
+	}
+
+	var encoder RowEncoder
+
+	if format != FormatTable {
+		encoder = NewRowEncoder(format, stdout, recordColumns)
+	}
+
+	for _, zone := range table.Resolve(hostname) {
 
 		lock.Lock()
 
-		go writeShort(ctx, provider, lock, rtype, hostname, stdout, stderr)
+		go writeShort(ctx, zone, lock, encoder, rtype, hostname, stdout, stderr)
 	}
 
 	lock.Wait()
+
+	if nil != encoder {
+		if err := encoder.Close(); err != nil {
+			_, _ = fmt.Fprintln(stderr, err.Error())
+		}
+	}
 }
 
-func writeShort(ctx context.Context, provider PluginProvider, lock sync.Locker, rtype string, hostname string, stdout, stderr io.Writer) {
+// writeShort prints the data of the matching records or, when an encoder
+// is given, encodes the matching records as rows.
+func writeShort(ctx context.Context, zone *ZoneEntry, lock sync.Locker, encoder RowEncoder, rtype string, hostname string, stdout, stderr io.Writer) {
 
 	defer lock.Unlock()
 
-	zones, err := provider.ListZones(ctx)
+	items, err := zone.Plugin.GetRecords(ctx, zone.Name)
 
 	if err != nil {
-		_, _ = fmt.Fprintf(stderr, "%s: error listing zones: %v\n", provider.Module().Path, err)
+		_, _ = fmt.Fprintf(stderr, "%s: error getting records for zone %s: %v\n", zone.Plugin.Module().Path, zone.Name, err)
 		return
 	}
 
-	for _, zone := range zones {
-
-		var zoneName = strings.TrimSuffix(zone.Name, ".")
+	if nil != encoder {
+		for _, row := range newRecordRows(zone.Plugin, zone.Name, items) {
+			if strings.EqualFold(strings.TrimSuffix(row.Name, "."), strings.TrimSuffix(hostname, ".")) && strings.EqualFold(row.Type, rtype) {
+				_ = encoder.Encode(row)
+			}
+		}
 
-		if hostname == zoneName || strings.HasSuffix(hostname, zoneName) {
-			items, err := provider.GetRecords(ctx, zone.Name)
+		return
+	}
 
-			if err != nil {
-				_, _ = fmt.Fprintf(stderr, "%s: error getting records for zone %s: %v\n", provider.Module().Path, zone.Name, err)
-				return
-			}
+	var zoneName = strings.TrimSuffix(zone.Name, ".")
 
-			for _, record := range items {
+	for _, record := range items {
 
-				var name = strings.TrimSuffix(libdns.AbsoluteName(record.RR().Name, zoneName), ".")
-				var rr = record.RR()
+		var name = strings.TrimSuffix(libdns.AbsoluteName(record.RR().Name, zoneName), ".")
+		var rr = record.RR()
 
-				if name == hostname && strings.EqualFold(rr.Type, rtype) {
-					_, _ = stdout.Write([]byte(rr.Data + "\n"))
-				}
-			}
+		if strings.EqualFold(name, strings.TrimSuffix(hostname, ".")) && strings.EqualFold(rr.Type, rtype) {
+			_, _ = stdout.Write([]byte(rr.Data + "\n"))
 		}
 	}
 }
@@ -4,54 +4,87 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"sort"
 	"sync"
 )
 
-func WriteZones(ctx context.Context, plugins []PluginProvider, lock WaitableLocker, stdout, stderr io.Writer, modules ...string) {
+func WriteZones(ctx context.Context, resolver *ZoneResolver, lock WaitableLocker, format Format, stdout, stderr io.Writer, modules ...string) {
 
 	var zones sync.Map
 
-	for _, provider := range plugins {
+	table, err := resolver.Fetch(ctx, lock, modules...)
+
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, err.Error())
+	}
+
+	for _, provider := range resolver.Plugins() {
 
 		if len(modules) > 0 && false == inSlice(modules, provider.Module().Path) {
 			continue
 		}
 
-		lock.Lock()
+		fetchZones(provider, table, &zones)
+	}
 
-		go fetchZones(ctx, lock, provider, &zones, stdout, stderr)
+	if format == FormatTable {
+		writeZones(&zones, stdout)
+		return
 	}
 
-	lock.Wait()
+	var encoder = NewRowEncoder(format, stdout, zoneColumns)
 
-	writeZones(&zones, stdout)
-}
+	for _, module := range zoneModules(&zones) {
 
-func fetchZones(ctx context.Context, lock sync.Locker, provider PluginProvider, mapped *sync.Map, stdout, stderr io.Writer) {
+		var value, _ = zones.Load(module)
 
-	defer lock.Unlock()
+		for _, zone := range value.([]string) {
+			_ = encoder.Encode(&ZoneRow{Module: module, Zone: zone})
+		}
+	}
+
+	if err := encoder.Close(); err != nil {
+		_, _ = fmt.Fprintln(stderr, err.Error())
+	}
+}
 
-	zones, err := provider.ListZones(ctx)
+func fetchZones(provider PluginProvider, table *ZoneTable, mapped *sync.Map) {
 
-	if err != nil {
-		_, _ = fmt.Fprintf(stderr, "%s: error listing zones: %v\n", provider.Module().Path, err)
+	var entries = table.Zones(provider)
+
+	if len(entries) == 0 {
 		return
 	}
 
-	var items = make([]string, len(zones))
+	var items = make([]string, len(entries))
 
-	for idx, zone := range zones {
+	for idx, zone := range entries {
 		items[idx] = zone.Name
 	}
 
 	mapped.Store(provider.Module().Path, items)
+}
+
+func zoneModules(mapped *sync.Map) []string {
+
+	var modules = make([]string, 0)
+
+	mapped.Range(func(key, _ interface{}) bool {
+		modules = append(modules, key.(string))
+		return true
+	})
 
+	sort.Strings(modules)
+
+	return modules
 }
 
 func writeZones(mapped *sync.Map, stdout io.Writer) {
-	mapped.Range(func(key, value interface{}) bool {
-		_, _ = fmt.Fprintf(stdout, "• %s\n", key.(string))
 
+	for _, module := range zoneModules(mapped) {
+		_, _ = fmt.Fprintf(stdout, "• %s\n", module)
+
+		var value, _ = mapped.Load(module)
 		var list = value.([]string)
 
 		for i, c := 0, len(list)-1; i <= c; i++ {
@@ -64,7 +97,5 @@ func writeZones(mapped *sync.Map, stdout io.Writer) {
 
 			_, _ = fmt.Fprintf(stdout, "%s%s\n", prefix, list[i])
 		}
-
-		return true
-	})
+	}
 }
@@ -21,21 +21,21 @@ func (w *Writer) Write(p []byte) (n int, err error) {
 }
 
 type ProviderDumper struct {
-	provider []PluginProvider
+	resolver *ZoneResolver
 	logger   *logger.Logger
 	lock     WaitableLocker
 }
 
-func (d *ProviderDumper) WriteZones(ctx context.Context, stdout, stderr io.Writer, modules ...string) {
-	WriteZones(ctx, d.provider, d.lock, stdout, stderr, modules...)
+func (d *ProviderDumper) WriteZones(ctx context.Context, format Format, stdout, stderr io.Writer, modules ...string) {
+	WriteZones(ctx, d.resolver, d.lock, format, stdout, stderr, modules...)
 }
 
-func (d *ProviderDumper) WriteRecords(ctx context.Context, stdout, stderr io.Writer, modules ...string) {
-	WriteRecords(ctx, d.provider, d.lock, stdout, stderr, modules...)
+func (d *ProviderDumper) WriteRecords(ctx context.Context, format Format, stdout, stderr io.Writer, modules ...string) {
+	WriteRecords(ctx, d.resolver, d.lock, format, stdout, stderr, modules...)
 }
 
-func (d *ProviderDumper) WriteShort(ctx context.Context, stdout, stderr io.Writer, rtype, hostname string) {
-	WriteShort(ctx, d.provider, d.lock, stdout, stderr, rtype, hostname)
+func (d *ProviderDumper) WriteShort(ctx context.Context, format Format, stdout, stderr io.Writer, rtype, hostname string) {
+	WriteShort(ctx, d.resolver, d.lock, format, stdout, stderr, rtype, hostname)
 }
 
 func NewDumper(logger *logger.Logger, level provider.OutputLevel) (*ProviderDumper, error) {
@@ -50,7 +50,7 @@ func NewDumper(logger *logger.Logger, level provider.OutputLevel) (*ProviderDump
 		return nil, err
 	}
 
-	return &ProviderDumper{lock: NewSemaphore(5), provider: providers}, nil
+	return &ProviderDumper{lock: NewSemaphore(5), resolver: NewZoneResolver(providers)}, nil
 }
 
 func inSlice[T comparable](arr []T, item T) bool {
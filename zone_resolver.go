@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/libdns/libdns"
+)
+
+// ZoneEntry is a zone as served by one of the plugins
+type ZoneEntry struct {
+	// Name as returned by the provider and should be used
+	// when calling the provider methods.
+	Name   string
+	Plugin PluginProvider
+	// Index of the plugin in the plugin list
+	Index  int
+	labels int
+	key    string
+}
+
+// Matches checks if the (lowercase) hostname is the zone apex or
+// a name within the zone, which is checked on label boundaries.
+func (z *ZoneEntry) Matches(hostname string) bool {
+	return hostname == z.key || strings.HasSuffix(hostname, "."+z.key)
+}
+
+// RelativeName returns the hostname relative to the zone
+func (z *ZoneEntry) RelativeName(hostname string) string {
+	return libdns.RelativeName(strings.ToLower(strings.TrimSuffix(hostname, ".")), z.key)
+}
+
+// ZoneResolver is responsible for finding the plugin(s) and zone that
+// serves a hostname. It does label-aware and case-insensitive lookups
+// and the most specific (longest) zone wins. When multiple plugins serve
+// the same zone, they are ordered by priority and then by config order.
+type ZoneResolver struct {
+	plugins []PluginProvider
+}
+
+func NewZoneResolver(plugins []PluginProvider) *ZoneResolver {
+	return &ZoneResolver{plugins: plugins}
+}
+
+func (r *ZoneResolver) Plugins() []PluginProvider {
+	return r.plugins
+}
+
+// Fetch will list the zones of all plugins (or the plugins matching
+// the given modules) and returns a table for resolving hostnames. When
+// one or more plugins failed listing their zones, the table is returned
+// with the zones that could be resolved and an error for the failed ones.
+func (r *ZoneResolver) Fetch(ctx context.Context, lock WaitableLocker, modules ...string) (*ZoneTable, error) {
+
+	var table = &ZoneTable{entries: make([]*ZoneEntry, 0), failed: make(map[int]error)}
+	var errs = make([]error, len(r.plugins))
+	var mutex sync.Mutex
+
+	for idx, plugin := range r.plugins {
+
+		if len(modules) > 0 && false == inSlice(modules, plugin.Module().Path) {
+			continue
+		}
+
+		lock.Lock()
+
+		go func() {
+			defer lock.Unlock()
+
+			zones, err := plugin.ListZones(ctx)
+
+			if err != nil {
+				errs[idx] = err
+				return
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			for _, zone := range zones {
+				var key = strings.ToLower(strings.TrimSuffix(zone.Name, "."))
+
+				table.entries = append(table.entries, &ZoneEntry{
+					Name:   zone.Name,
+					Plugin: plugin,
+					Index:  idx,
+					labels: strings.Count(key, ".") + 1,
+					key:    key,
+				})
+			}
+		}()
+	}
+
+	lock.Wait()
+
+	for idx, err := range errs {
+		if err != nil {
+			table.failed[idx] = err
+			errs[idx] = fmt.Errorf("%s: error listing zones: %w", r.plugins[idx].Module().Path, err)
+		}
+	}
+
+	table.sort()
+
+	return table, errors.Join(errs...)
+}
+
+type ZoneTable struct {
+	entries []*ZoneEntry
+	// failed holds the error of ListZones per plugin
+	// index for the plugins that could not list their zones
+	failed map[int]error
+}
+
+// Failed returns the errors of the plugins (by index) that could not list
+// their zones. The zones of these plugins are missing from the table, so a
+// hostname could resolve to a less specific zone than it should.
+func (t *ZoneTable) Failed() map[int]error {
+	return t.failed
+}
+
+// sort orders the entries by the number of labels (most specific
+// first), then by plugin priority and finally by the config order.
+func (t *ZoneTable) sort() {
+	slices.SortStableFunc(t.entries, func(a, b *ZoneEntry) int {
+
+		if a.labels != b.labels {
+			return b.labels - a.labels
+		}
+
+		if x, y := a.Plugin.Priority(), b.Plugin.Priority(); x != y {
+			return y - x
+		}
+
+		if a.Index != b.Index {
+			return a.Index - b.Index
+		}
+
+		return strings.Compare(a.key, b.key)
+	})
+}
+
+// Resolve returns the entries for the longest zone that matches the
+// hostname, ordered by priority. So the first entry is the preferred
+// plugin and the other entries are plugins that serve the same zone.
+func (t *ZoneTable) Resolve(hostname string) []*ZoneEntry {
+
+	var matches = make([]*ZoneEntry, 0)
+
+	hostname = strings.ToLower(strings.TrimSuffix(hostname, "."))
+
+	for _, entry := range t.entries {
+
+		if len(matches) > 0 && matches[0].labels != entry.labels {
+			break
+		}
+
+		if entry.Matches(hostname) {
+			matches = append(matches, entry)
+		}
+	}
+
+	return matches
+}
+
+// Lookup returns the entries of the zone (exact match), ordered by priority
+func (t *ZoneTable) Lookup(zone string) []*ZoneEntry {
+
+	var matches = make([]*ZoneEntry, 0)
+
+	zone = strings.ToLower(strings.TrimSuffix(zone, "."))
+
+	for _, entry := range t.entries {
+		if entry.key == zone {
+			matches = append(matches, entry)
+		}
+	}
+
+	return matches
+}
+
+// Entries returns all entries ordered by zone name, priority and config order
+func (t *ZoneTable) Entries() []*ZoneEntry {
+
+	var entries = slices.Clone(t.entries)
+
+	slices.SortStableFunc(entries, func(a, b *ZoneEntry) int {
+		return strings.Compare(a.key, b.key)
+	})
+
+	return entries
+}
+
+// Zones returns the zones served by given plugin
+func (t *ZoneTable) Zones(plugin PluginProvider) []*ZoneEntry {
+
+	var zones = make([]*ZoneEntry, 0)
+
+	for _, entry := range t.entries {
+		if entry.Plugin == plugin {
+			zones = append(zones, entry)
+		}
+	}
+
+	slices.SortFunc(zones, func(a, b *ZoneEntry) int {
+		return strings.Compare(a.key, b.key)
+	})
+
+	return zones
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestZoneTableResolve(t *testing.T) {
+
+	var a = newTestProvider("a", "example.com", "home.example.com")
+	var b = newTestProvider("b", "example.com.")
+	var c = newTestProvider("c", "Example.COM", "home.example.com.")
+
+	b.priority = 10
+
+	table, err := NewZoneResolver([]PluginProvider{a, b, c}).Fetch(context.Background(), NewSemaphore(2))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for hostname, expected := range map[string][]string{
+		"example.com":           {"b", "a", "c"},
+		"WWW.Example.com.":      {"b", "a", "c"},
+		"home.example.com":      {"a", "c"},
+		"www.home.example.com":  {"a", "c"},
+		"xhome.example.com":     {"b", "a", "c"},
+		"notexample.com":        {},
+		"www.example.org":       {},
+		"home.example.com.test": {},
+	} {
+		var modules = make([]string, 0)
+
+		for _, entry := range table.Resolve(hostname) {
+			modules = append(modules, entry.Plugin.Module().Path)
+		}
+
+		if false == slices.Equal(modules, expected) {
+			t.Errorf("%s: expected %v got %v", hostname, expected, modules)
+		}
+	}
+}
@@ -0,0 +1,594 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// parseTTL parses a TTL in seconds or with the units (w, d, h, m
+// and s) as supported in BIND zone files, like 1h or 1d12h.
+func parseTTL(value string) (time.Duration, error) {
+
+	if seconds, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	var ttl time.Duration
+	var num int
+
+	for i, c := 0, len(value); i < c; i++ {
+
+		if x := value[i]; x >= '0' && x <= '9' {
+			num = (num * 10) + int(x-'0')
+			continue
+		}
+
+		var unit time.Duration
+
+		switch value[i] {
+		case 'w', 'W':
+			unit = 7 * 24 * time.Hour
+		case 'd', 'D':
+			unit = 24 * time.Hour
+		case 'h', 'H':
+			unit = time.Hour
+		case 'm', 'M':
+			unit = time.Minute
+		case 's', 'S':
+			unit = time.Second
+		}
+
+		if unit == 0 || i == 0 || false == isDigits(value[i-1:i]) {
+			return 0, errors.New("invalid ttl '" + value + "'")
+		}
+
+		ttl, num = ttl+(time.Duration(num)*unit), 0
+	}
+
+	if ttl += time.Duration(num) * time.Second; value == "" || ttl > time.Duration(math.MaxUint32)*time.Second {
+		return 0, errors.New("invalid ttl '" + value + "'")
+	}
+
+	return ttl, nil
+}
+
+// quoteText returns the text as one or more quoted character strings,
+// which are split at 255 bytes as a single string can not be longer.
+func quoteText(text string) string {
+
+	var buf strings.Builder
+
+	for {
+		var chunk = text
+
+		if len(chunk) > 255 {
+			chunk = chunk[:255]
+		}
+
+		text = text[len(chunk):]
+
+		buf.WriteByte('"')
+
+		for i, c := 0, len(chunk); i < c; i++ {
+			switch x := chunk[i]; {
+			case x == '"' || x == '\\':
+				buf.WriteByte('\\')
+				buf.WriteByte(x)
+			case x < ' ' || x > '~':
+				buf.WriteByte('\\')
+				buf.WriteString(strconv.Itoa(int(x) + 1000)[1:])
+			default:
+				buf.WriteByte(x)
+			}
+		}
+
+		buf.WriteByte('"')
+
+		if text == "" {
+			return buf.String()
+		}
+
+		buf.WriteByte(' ')
+	}
+}
+
+// unquoteText returns the text of (space separated) character strings as
+// used for TXT records in zone files, where the strings are concatenated.
+// Text that does not start with a quote is returned as is.
+func unquoteText(value string) (string, error) {
+
+	if false == strings.HasPrefix(value, `"`) {
+		return value, nil
+	}
+
+	var buf strings.Builder
+	var quoted bool
+
+	for i, c := 0, len(value); i < c; i++ {
+
+		var x = value[i]
+
+		switch {
+		case x == '"':
+			quoted = false == quoted
+		case x == '\\':
+
+			if i+1 >= c {
+				return "", errors.New("invalid escape at end of text")
+			}
+
+			if i+3 < c && isDigits(value[i+1:i+4]) {
+
+				n, _ := strconv.Atoi(value[i+1 : i+4])
+
+				if n > 255 {
+					return "", errors.New("invalid escape '\\" + value[i+1:i+4] + "'")
+				}
+
+				buf.WriteByte(byte(n))
+				i += 3
+			} else {
+				buf.WriteByte(value[i+1])
+				i++
+			}
+		case quoted:
+			buf.WriteByte(x)
+		case x != ' ' && x != '\t':
+			return "", errors.New("unexpected '" + string(x) + "' outside of quoted text")
+		}
+	}
+
+	if quoted {
+		return "", errors.New("unterminated quoted text")
+	}
+
+	return buf.String(), nil
+}
+
+func isDigits(value string) bool {
+
+	for i, c := 0, len(value); i < c; i++ {
+		if value[i] < '0' || value[i] > '9' {
+			return false
+		}
+	}
+
+	return value != ""
+}
+
+// WriteZoneFile writes the records as RFC 1035 master file where the names
+// are relative to the $ORIGIN and the most used TTL is set as $TTL (which
+// is left out for an empty zone).
+func WriteZoneFile(writer io.Writer, zone string, records []libdns.Record) error {
+
+	var origin = strings.ToLower(fqdn(zone))
+	var items = make([]libdns.RR, len(records))
+	var counts = make(map[time.Duration]int)
+	var ttl time.Duration
+
+	for idx, record := range records {
+		items[idx] = record.RR()
+		items[idx].Name = strings.ToLower(libdns.AbsoluteName(items[idx].Name, origin))
+		counts[items[idx].TTL]++
+	}
+
+	for value, count := range counts {
+		if count > counts[ttl] || (count == counts[ttl] && value < ttl) {
+			ttl = value
+		}
+	}
+
+	slices.SortStableFunc(items, func(a, b libdns.RR) int {
+		return compareZoneRecords(a, b, origin)
+	})
+
+	var tab = tabwriter.NewWriter(writer, 0, 8, 1, ' ', 0)
+
+	_, _ = fmt.Fprintf(tab, "$ORIGIN %s\n", origin)
+	// an empty zone has no ttl to use as default
+	if len(items) > 0 {
+		_, _ = fmt.Fprintf(tab, "$TTL %d\n", int64(ttl/time.Second))
+	}
+
+	for _, rr := range items {
+
+		var value string
+
+		if rr.TTL != ttl {
+			value = strconv.FormatInt(int64(rr.TTL/time.Second), 10)
+		}
+
+		_, _ = fmt.Fprintf(tab, "%s\t%s\tIN\t%s\t%s\n", libdns.RelativeName(rr.Name, origin), value, rr.Type, zoneData(rr))
+	}
+
+	return tab.Flush()
+}
+
+// compareZoneRecords sorts the records of the zone apex (with the SOA
+// record first) before the other names, and then by name, type and data.
+func compareZoneRecords(a, b libdns.RR, origin string) int {
+
+	if x, y := a.Name == origin, b.Name == origin; x != y {
+		if x {
+			return -1
+		}
+		return 1
+	}
+
+	if x, y := a.Type == "SOA", b.Type == "SOA"; x != y {
+		if x {
+			return -1
+		}
+		return 1
+	}
+
+	if ret := strings.Compare(a.Name, b.Name); ret != 0 {
+		return ret
+	}
+
+	if ret := strings.Compare(a.Type, b.Type); ret != 0 {
+		return ret
+	}
+
+	return strings.Compare(a.Data, b.Data)
+}
+
+// zoneData returns the data of the record as written in a zone file
+func zoneData(rr libdns.RR) string {
+
+	if strings.EqualFold(rr.Type, "TXT") {
+		return quoteText(rr.Data)
+	}
+
+	return rr.Data
+}
+
+// ParseZoneFile parses a RFC 1035 master file with the records of given
+// zone. It supports the $ORIGIN and $TTL directives, comments, multi line
+// records (with parentheses) and records that inherit the owner of the
+// previous record. The names of the returned records are relative to the
+// zone and names outside the zone are an error.
+func ParseZoneFile(reader io.Reader, zone string) ([]libdns.Record, error) {
+
+	var parser = newZoneParser(zone)
+	var scanner = bufio.NewScanner(reader)
+	var records = make([]libdns.Record, 0)
+	var entry strings.Builder
+	var depth, line, start int
+
+	for scanner.Scan() {
+
+		line++
+
+		var text, open, err = stripZoneLine(scanner.Text())
+
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if depth == 0 {
+			start = line
+			entry.Reset()
+		}
+
+		entry.WriteString(text)
+		entry.WriteByte(' ')
+
+		if depth += open; depth < 0 {
+			return nil, fmt.Errorf("line %d: unexpected ')'", line)
+		}
+
+		if depth > 0 {
+			continue
+		}
+
+		record, err := parser.parse(entry.String())
+
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", start, err)
+		}
+
+		if nil != record {
+			records = append(records, record)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if depth > 0 {
+		return nil, fmt.Errorf("line %d: missing ')'", start)
+	}
+
+	return records, nil
+}
+
+// stripZoneLine removes the comment and parentheses from the line
+// and returns the number of opened (or closed when negative) parentheses.
+func stripZoneLine(line string) (string, int, error) {
+
+	var buf strings.Builder
+	var quoted bool
+	var open int
+
+	for i, c := 0, len(line); i < c; i++ {
+
+		var x = line[i]
+
+		switch {
+		case x == '\\' && i+1 < c:
+			buf.WriteByte(x)
+			buf.WriteByte(line[i+1])
+			i++
+			continue
+		case x == '"':
+			quoted = false == quoted
+		case quoted:
+		case x == ';':
+			return buf.String(), open, nil
+		case x == '(':
+			open++
+			x = ' '
+		case x == ')':
+			open--
+			x = ' '
+		}
+
+		buf.WriteByte(x)
+	}
+
+	if quoted {
+		return "", 0, errors.New("unterminated quoted text")
+	}
+
+	return buf.String(), open, nil
+}
+
+// zoneFields splits the entry in fields, where quoted text is kept as one field
+func zoneFields(entry string) []string {
+
+	var fields = make([]string, 0)
+	var buf strings.Builder
+	var quoted bool
+
+	for i, c := 0, len(entry); i < c; i++ {
+
+		var x = entry[i]
+
+		switch {
+		case x == '\\' && i+1 < c:
+			buf.WriteByte(x)
+			x = entry[i+1]
+			i++
+		case x == '"':
+			quoted = false == quoted
+		case false == quoted && (x == ' ' || x == '\t'):
+			if buf.Len() > 0 {
+				fields = append(fields, buf.String())
+				buf.Reset()
+			}
+			continue
+		}
+
+		buf.WriteByte(x)
+	}
+
+	if buf.Len() > 0 {
+		fields = append(fields, buf.String())
+	}
+
+	return fields
+}
+
+type zoneParser struct {
+	zone   string
+	origin string
+	owner  string
+	// ttl as defined with $TTL
+	ttl    time.Duration
+	hasTTL bool
+	// last explicit ttl, used when no $TTL is defined (RFC 1035)
+	last    time.Duration
+	hasLast bool
+}
+
+func newZoneParser(zone string) *zoneParser {
+
+	var parser = &zoneParser{zone: strings.ToLower(fqdn(zone))}
+
+	parser.origin = parser.zone
+
+	return parser
+}
+
+func (p *zoneParser) parse(entry string) (libdns.Record, error) {
+
+	var fields = zoneFields(entry)
+
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(fields[0], "$") {
+		return nil, p.directive(fields)
+	}
+
+	var owner = p.owner
+
+	if entry[0] != ' ' && entry[0] != '\t' {
+
+		name, err := p.name(fields[0])
+
+		if err != nil {
+			return nil, err
+		}
+
+		owner, fields = name, fields[1:]
+	}
+
+	if owner == "" {
+		return nil, errors.New("record without owner")
+	}
+
+	var rr = libdns.RR{Name: owner, TTL: p.ttl}
+	var hasTTL = p.hasTTL
+
+	if false == hasTTL {
+		rr.TTL, hasTTL = p.last, p.hasLast
+	}
+
+	for len(fields) > 0 {
+
+		if isDigits(fields[0][:1]) {
+
+			ttl, err := parseTTL(fields[0])
+
+			if err != nil {
+				return nil, err
+			}
+
+			rr.TTL, hasTTL, fields = ttl, true, fields[1:]
+			p.last, p.hasLast = ttl, true
+			continue
+		}
+
+		if class := strings.ToUpper(fields[0]); class == "IN" || class == "CH" || class == "HS" || class == "CS" {
+
+			if class != "IN" {
+				return nil, errors.New("unsupported class " + class)
+			}
+
+			fields = fields[1:]
+			continue
+		}
+
+		break
+	}
+
+	if len(fields) < 2 {
+		return nil, errors.New("record requires a type and data")
+	}
+
+	if false == hasTTL {
+		return nil, errors.New("record without ttl (and no $TTL defined)")
+	}
+
+	rr.Type = strings.ToUpper(fields[0])
+
+	data, err := p.data(rr.Type, fields[1:])
+
+	if err != nil {
+		return nil, err
+	}
+
+	rr.Data = data
+	p.owner = owner
+
+	rr.Name = libdns.RelativeName(rr.Name, p.zone)
+
+	return rr.Parse()
+}
+
+func (p *zoneParser) directive(fields []string) error {
+
+	switch strings.ToUpper(fields[0]) {
+	case "$ORIGIN":
+
+		if len(fields) != 2 {
+			return errors.New("$ORIGIN requires a name")
+		}
+
+		origin, err := p.name(fields[1])
+
+		if err != nil {
+			return err
+		}
+
+		p.origin = origin
+	case "$TTL":
+
+		if len(fields) != 2 {
+			return errors.New("$TTL requires a ttl")
+		}
+
+		ttl, err := parseTTL(fields[1])
+
+		if err != nil {
+			return err
+		}
+
+		p.ttl, p.hasTTL = ttl, true
+	default:
+		return errors.New("unsupported directive " + fields[0])
+	}
+
+	return nil
+}
+
+// name returns the absolute name, where a name without trailing dot
+// is relative to the origin and @ is the origin.
+func (p *zoneParser) name(value string) (string, error) {
+
+	var name = p.absolute(value)
+
+	if name != p.zone && false == strings.HasSuffix(name, "."+p.zone) {
+		return "", fmt.Errorf("name '%s' is outside of zone '%s'", value, p.zone)
+	}
+
+	return name, nil
+}
+
+func (p *zoneParser) absolute(value string) string {
+
+	if value == "@" {
+		return p.origin
+	}
+
+	if strings.HasSuffix(value, ".") {
+		return strings.ToLower(value)
+	}
+
+	return strings.ToLower(value) + "." + p.origin
+}
+
+// data returns the record data where the quoted strings of TXT records
+// are unquoted and the (relative) names in the data of the common record
+// types are made absolute.
+func (p *zoneParser) data(rtype string, fields []string) (string, error) {
+
+	if rtype == "TXT" {
+		return unquoteText(strings.Join(fields, " "))
+	}
+
+	var names []int
+
+	switch rtype {
+	case "CNAME", "NS", "PTR", "DNAME":
+		names = []int{0}
+	case "MX":
+		names = []int{1}
+	case "SRV":
+		names = []int{3}
+	case "SOA":
+		names = []int{0, 1}
+	}
+
+	for _, idx := range names {
+		if idx < len(fields) && fields[idx] != "." {
+			fields[idx] = p.absolute(fields[idx])
+		}
+	}
+
+	return strings.Join(fields, " "), nil
+}
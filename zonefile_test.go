@@ -0,0 +1,173 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+func TestParseTTL(t *testing.T) {
+
+	for value, expected := range map[string]time.Duration{
+		"0":       0,
+		"300":     5 * time.Minute,
+		"1h":      time.Hour,
+		"1H30M":   90 * time.Minute,
+		"1d12h":   36 * time.Hour,
+		"1w":      7 * 24 * time.Hour,
+		"1m30":    90 * time.Second,
+		"2h0m10s": 2*time.Hour + 10*time.Second,
+	} {
+		ttl, err := parseTTL(value)
+
+		if err != nil {
+			t.Errorf("%s: %s", value, err)
+			continue
+		}
+
+		if ttl != expected {
+			t.Errorf("%s: expected %s got %s", value, expected, ttl)
+		}
+	}
+
+	for _, value := range []string{"", "h", "1x", "1hh", "-1", "4294967296"} {
+		if _, err := parseTTL(value); err == nil {
+			t.Errorf("%s: expected an error", value)
+		}
+	}
+}
+
+func TestParseZoneFile(t *testing.T) {
+
+	var zone = `$ORIGIN example.com.
+$TTL 1h
+@	IN	SOA	ns1 hostmaster (
+		2024010101 ; serial
+		3600       ; refresh
+		900        ; retry
+		1209600    ; expire
+		300 )      ; minimum
+	IN	NS	ns1
+	IN	MX	10 mail
+www	300	IN	A	192.0.2.1
+	IN	AAAA	2001:db8::1
+txt	IN	TXT	"v=spf1 ; not a comment" "second" ; comment
+$ORIGIN sub.example.com.
+host	CNAME	www.example.com.
+srv	CNAME	@
+_sip._tcp	60	SRV	10 5 5060 sip
+`
+
+	records, err := ParseZoneFile(strings.NewReader(zone), "example.com")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var expected = []libdns.RR{
+		{Name: "@", TTL: time.Hour, Type: "SOA", Data: "ns1.example.com. hostmaster.example.com. 2024010101 3600 900 1209600 300"},
+		{Name: "@", TTL: time.Hour, Type: "NS", Data: "ns1.example.com."},
+		{Name: "@", TTL: time.Hour, Type: "MX", Data: "10 mail.example.com."},
+		{Name: "www", TTL: 5 * time.Minute, Type: "A", Data: "192.0.2.1"},
+		{Name: "www", TTL: time.Hour, Type: "AAAA", Data: "2001:db8::1"},
+		{Name: "txt", TTL: time.Hour, Type: "TXT", Data: "v=spf1 ; not a commentsecond"},
+		{Name: "host.sub", TTL: time.Hour, Type: "CNAME", Data: "www.example.com."},
+		{Name: "srv.sub", TTL: time.Hour, Type: "CNAME", Data: "sub.example.com."},
+		{Name: "_sip._tcp.sub", TTL: time.Minute, Type: "SRV", Data: "10 5 5060 sip.sub.example.com."},
+	}
+
+	if len(records) != len(expected) {
+		t.Fatalf("expected %d records got %d", len(expected), len(records))
+	}
+
+	for idx, record := range records {
+		if rr := record.RR(); rr != expected[idx] {
+			t.Errorf("record %d: expected %+v got %+v", idx, expected[idx], rr)
+		}
+	}
+}
+
+func TestParseZoneFileLastTTL(t *testing.T) {
+
+	records, err := ParseZoneFile(strings.NewReader("www 300 IN A 192.0.2.1\nmail IN A 192.0.2.2\n"), "example.com.")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 2 || records[1].RR().TTL != 5*time.Minute {
+		t.Errorf("expected the last ttl to be used, got %+v", records)
+	}
+}
+
+func TestParseZoneFileInvalid(t *testing.T) {
+
+	for name, zone := range map[string]string{
+		"outside zone":           "$TTL 300\nwww.example.org. IN A 192.0.2.1\n",
+		"origin outside zone":    "$TTL 300\n$ORIGIN example.org.\nwww IN A 192.0.2.1\n",
+		"missing parenthesis":    "$TTL 300\n@ IN SOA ns1 hostmaster ( 1 2 3 4 5\n",
+		"unexpected parenthesis": "$TTL 300\n@ IN SOA ns1 hostmaster 1 2 3 4 5 )\n",
+		"unterminated quote":     "$TTL 300\ntxt IN TXT \"text\n",
+		"missing ttl":            "www IN A 192.0.2.1\n",
+		"unsupported class":      "$TTL 300\nwww CH A 192.0.2.1\n",
+		"unsupported directive":  "$INCLUDE other.zone\n",
+		"invalid ttl":            "$TTL 1x\n",
+	} {
+		if _, err := ParseZoneFile(strings.NewReader(zone), "example.com."); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestWriteZoneFile(t *testing.T) {
+
+	var records = []libdns.Record{
+		libdns.RR{Name: "www", TTL: time.Hour, Type: "A", Data: "192.0.2.1"},
+		libdns.RR{Name: "txt", TTL: 5 * time.Minute, Type: "TXT", Data: "text with \"quotes\""},
+		libdns.RR{Name: "@", TTL: time.Hour, Type: "NS", Data: "ns1.example.com."},
+		libdns.RR{Name: "@", TTL: time.Hour, Type: "SOA", Data: "ns1.example.com. hostmaster.example.com. 1 3600 900 1209600 300"},
+	}
+
+	var buf strings.Builder
+
+	if err := WriteZoneFile(&buf, "example.com", records); err != nil {
+		t.Fatal(err)
+	}
+
+	if false == strings.Contains(buf.String(), "$TTL 3600\n") {
+		t.Errorf("expected the most used ttl as default:\n%s", buf.String())
+	}
+
+	parsed, err := ParseZoneFile(strings.NewReader(buf.String()), "example.com")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var expected = []libdns.RR{records[3].RR(), records[2].RR(), records[1].RR(), records[0].RR()}
+
+	if len(parsed) != len(expected) {
+		t.Fatalf("expected %d records got %d:\n%s", len(expected), len(parsed), buf.String())
+	}
+
+	for idx, record := range parsed {
+		if rr := record.RR(); rr != expected[idx] {
+			t.Errorf("record %d: expected %+v got %+v", idx, expected[idx], rr)
+		}
+	}
+}
+
+func TestWriteZoneFileEmpty(t *testing.T) {
+
+	var buf strings.Builder
+
+	if err := WriteZoneFile(&buf, "example.com", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != "$ORIGIN example.com.\n" {
+		t.Errorf("unexpected empty zone %q", buf.String())
+	}
+}